@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/simulate"
+)
+
+var (
+	numFunds = flag.Int("numFunds", 1, "How many funds the strategies choose")
+	months   = flag.Int("months", 12, "How many months the strategies read")
+	seed     = flag.Int64("seed", 1, "The seed of the random strategy")
+	spread   = flag.Float64("spread", 0.02, "How much above CDI per year funds must have returned")
+	config   = flag.String("config", "", "A Weighted strategy to compare to the baselines, as written by optimize")
+)
+
+func main() {
+	flag.Parse()
+	funds := xpfunds.ReadFunds()
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	b := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		MinMonths:   *months,
+		NumFunds:    *numFunds,
+		Cash:        cdi,
+		CDI:         cdi,
+	}
+	strategies := []simulate.Strategy{
+		&simulate.Momentum{NumFunds: *numFunds, Months: *months},
+		&simulate.LowVolatility{NumFunds: *numFunds, Months: *months},
+		&simulate.Sharpe{NumFunds: *numFunds, Months: *months, CDI: cdi},
+		&simulate.RiskParity{Strategy: &simulate.Sharpe{NumFunds: *numFunds, Months: *months, CDI: cdi}, Months: *months},
+		&simulate.CDIPlus{NumFunds: *numFunds, Months: *months, CDI: cdi, Spread: *spread},
+		&simulate.Random{NumFunds: *numFunds, Seed: *seed},
+		simulate.Filter(
+			simulate.And(simulate.MaxMinimum(5000), simulate.MaxDays(30)),
+			&simulate.Sharpe{NumFunds: *numFunds, Months: *months, CDI: cdi}),
+	}
+	fmt.Println("strategy\tmedian\tmean\tworst\tbeatCDI\trelativeCDI")
+	for _, s := range strategies {
+		run(b, s)
+	}
+	if *config != "" {
+		c, err := simulate.ReadWeightedConfig(*config)
+		check.Check(err)
+		w, err := c.Weighted()
+		check.Check(err)
+		// The strategy is simulated with the minimum months it was chosen with.
+		cb := b.Clone()
+		cb.MinMonths = c.MinMonths
+		run(cb, w)
+	}
+}
+
+func run(b *simulate.Backtest, s simulate.Strategy) {
+	r, err := b.Run(s)
+	if err != nil {
+		fmt.Printf("%v\t%v\n", s.Name(), err)
+		return
+	}
+	fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", s.Name(), r.Median, r.Mean, r.Worst, r.BeatCDI, r.MedianRelative(simulate.CDI))
+}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/simulate"
 )
 
 var months = flag.Int("months", -1,
@@ -11,7 +13,6 @@ var months = flag.Int("months", -1,
 
 func main() {
 	flag.Parse()
-	cdi := xpfunds.FundFromFile("cdi.tsv")
 	funds := xpfunds.ReadFunds()
 	var duration int
 	if *months == -1 {
@@ -20,18 +21,12 @@ func main() {
 		duration = *months
 	}
 
-	ratio_total := 0.0
-	for time := 0; time < duration; time++ {
-		monthly_total := 0.0
-		monthly_count := 0
-		for _, f := range funds {
-			if time >= f.Duration() {
-				continue
-			}
-			monthly_total += f.Monthly[time]
-			monthly_count++
-		}
-		ratio_total += monthly_total / float64(monthly_count) / cdi.Monthly[time]
+	b := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: duration,
+		CDI:         xpfunds.FundFromFile("cdi.tsv"),
 	}
-	fmt.Println(ratio_total / float64(duration))
+	r, err := b.Run(simulate.AllFunds{})
+	check.Check(err)
+	fmt.Println(r.MedianRelative(simulate.CDI))
 }
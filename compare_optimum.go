@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/simulate"
 )
 
 var months = flag.Int("months", -1,
@@ -11,18 +13,20 @@ var months = flag.Int("months", -1,
 
 func main() {
 	flag.Parse()
-	cdi := xpfunds.FundFromFile("cdi.tsv")
-	optimum := xpfunds.NewOptimum(xpfunds.ReadFunds())
+	funds := xpfunds.ReadFunds()
 	var duration int
 	if *months == -1 {
-		duration = len(optimum.Period)
+		duration = xpfunds.MaxDuration(funds)
 	} else {
 		duration = *months
 	}
 
-	ratio_total := 0.0
-	for time := 0; time < duration; time++ {
-		ratio_total += optimum.Period[time][0] / xpfunds.Annual(cdi.Monthly[time], 0, 1)
+	b := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: duration,
+		CDI:         xpfunds.FundFromFile("cdi.tsv"),
 	}
-	fmt.Println(ratio_total / float64(duration))
+	r, err := b.Run(&simulate.Hindsight{NumFunds: 1})
+	check.Check(err)
+	fmt.Println(r.MedianRelative(simulate.CDI))
 }
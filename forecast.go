@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/dataset"
+	"xpfunds/forecast"
+	"xpfunds/simulate"
+)
+
+var (
+	features   = flag.String("features", strings.Join(xpfunds.FeatureNames, ","), "The features, comma separated, from: "+strings.Join(dataset.Registered(), ","))
+	label      = flag.String("label", "return12", "The label predicted, one of "+strings.Join(dataset.LabelKinds, ",")+" followed by the months")
+	months     = flag.Int("months", 12, "How many months the features read, 0 for all")
+	validation = flag.Int("validation", 24, "How many months before the test split are used to choose lambda")
+	testMonths = flag.Int("test_months", 36, "How many of the most recent months are used for test")
+	lambdas    = flag.String("lambdas", "0,0.1,1,10,100", "The ridge penalties tried, comma separated, 0 for ordinary least squares")
+	numFunds   = flag.Int("numFunds", 5, "How many funds the strategy chooses")
+)
+
+func main() {
+	flag.Parse()
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	spec := &dataset.Spec{
+		Features:   strings.Split(*features, ","),
+		Labels:     []string{*label},
+		Months:     *months,
+		Validation: *validation,
+		Test:       *testMonths,
+		CDI:        cdi,
+		IPCA:       xpfunds.FundFromFile("ipca.tsv"),
+	}
+	funds := xpfunds.ReadFunds()
+	d, err := spec.Build(funds)
+	check.Check(err)
+	fmt.Printf("rows: train %v, validation %v, test %v\n", len(d.Train), len(d.Validation), len(d.Test))
+
+	var best *forecast.Linear
+	bestLambda, bestCorrelation := 0.0, 0.0
+	for _, field := range strings.Split(*lambdas, ",") {
+		lambda, err := strconv.ParseFloat(field, 64)
+		check.Check(err)
+		m, err := forecast.Fit(d.Train, 0, lambda)
+		if err != nil {
+			fmt.Printf("lambda %v: %v\n", lambda, err)
+			continue
+		}
+		e := forecast.Evaluate(m, d.Validation, 0)
+		fmt.Printf("lambda %v: validation %v\n", lambda, e)
+		// Without validation dates the lambdas can't be compared.
+		if math.IsNaN(e.RankCorrelation) {
+			continue
+		}
+		if best == nil || e.RankCorrelation > bestCorrelation {
+			best, bestLambda, bestCorrelation = m, lambda, e.RankCorrelation
+		}
+	}
+	if best == nil {
+		check.Check(errors.New("no lambda has a validation rank correlation"))
+	}
+	fmt.Printf("lambda %v: test %v\n", bestLambda, forecast.Evaluate(best, d.Test, 0))
+	fmt.Printf("intercept %v, coefficients %v\n", best.Intercept, best.Coefficients)
+
+	b := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		MinMonths:   *months,
+		NumFunds:    *numFunds,
+		Start:       *testMonths,
+		Cash:        cdi,
+		CDI:         cdi,
+	}
+	fmt.Println("strategy\tmedian\tmean\tworst\tbeatCDI")
+	for _, s := range []simulate.Strategy{&forecast.Strategy{Model: best, Spec: spec, NumFunds: *numFunds}, simulate.AllFunds{}} {
+		r, err := b.Run(s)
+		if err != nil {
+			fmt.Printf("%v\t%v\n", s.Name(), err)
+			continue
+		}
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", s.Name(), r.Median, r.Mean, r.Worst, r.BeatCDI)
+	}
+}
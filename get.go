@@ -48,8 +48,12 @@ func get(url string) *goquery.Document {
 func processFund(url string) {
 	doc := get("https://portal.xpi.com.br" + url)
 	active := "true"
-	if doc.Find("input[value=\"Quero aplicar agora\"]").Length() != 1 || doc.FindMatcher(containsMatcher{"ualificados"}).Length() == 1 {
+	if doc.Find("input[value=\"Quero aplicar agora\"]").Length() != 1 {
 		active = "false"
+	} else if doc.FindMatcher(containsMatcher{"rofissionais"}).Length() == 1 {
+		active = "professional"
+	} else if doc.FindMatcher(containsMatcher{"ualificados"}).Length() == 1 {
+		active = "qualified"
 	}
 	name := doc.Find("h2.fleft").Text()
 	minText := doc.FindMatcher(containsMatcher{"Aplicação Inicial Mínima"}).Next().Text()
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/grid"
+	"xpfunds/manifest"
+	"xpfunds/simulate"
+)
+
+var (
+	specFile     = flag.String("spec", "grid.json", "The values of the parameters to evaluate")
+	out          = flag.String("out", "grid.tsv", "Where to write the results")
+	sortBy       = flag.String("sort", "median", "The column the results are sorted by")
+	manifestFile = flag.String("manifest", "grid_manifest.json", "Where to write the description of the run")
+	profileName  = flag.String("profile", "retail", "The investor whose investable funds are chosen: retail, qualified or professional")
+)
+
+var rebalance = 0
+
+func main() {
+	flag.Parse()
+	manifest.New(0, *specFile, "get.tsv", "delisted.tsv", "cdi.tsv").Write(*manifestFile)
+	profile, err := xpfunds.ParseProfile(*profileName)
+	check.Check(err)
+	spec, err := grid.ReadSpec(*specFile)
+	check.Check(err)
+	funds := xpfunds.ReadFunds()
+	maxDuration := 0
+	for _, f := range funds {
+		if f.Duration() > maxDuration {
+			maxDuration = f.Duration()
+		}
+	}
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	backtest := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: maxDuration,
+		Rebalance:   rebalance,
+		Cash:        cdi,
+		CDI:         cdi,
+
+		OnlyInvestable: true,
+		Profile:        profile,
+	}
+	rows, err := spec.Run(backtest)
+	check.Check(err)
+	check.Check(grid.Sort(rows, *sortBy))
+	f, err := os.Create(*out)
+	check.Check(err)
+	defer f.Close()
+	check.Check(grid.WriteTSV(f, rows))
+	fmt.Printf("%v points written to %v\n", len(rows), *out)
+}
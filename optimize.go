@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/manifest"
+	"xpfunds/optimize"
+	"xpfunds/search"
+	"xpfunds/simulate"
+)
+
+var (
+	algorithm    = flag.String("algorithm", "annealing", "The optimizer: annealing, cmaes, genetic, nelderMead, perturb or shrinking")
+	seed         = flag.Int64("seed", 1, "The seed of the random numbers")
+	iterations   = flag.Int("iterations", 0, "The maximum number of iterations, or 0 for no limit")
+	duration     = flag.Duration("duration", 0, "The maximum time running, or 0 for no limit")
+	patience     = flag.Int("patience", 0, "The maximum number of iterations without improvement, or 0 for no limit")
+	restarts     = flag.Int("restarts", 0, "The maximum number of restarts of cmaes and nelderMead after converging")
+	restartFrom  = flag.String("restart", "best", "Where cmaes and nelderMead restart: best or random")
+	growth       = flag.Float64("growth", 2, "What the population of cmaes is multiplied by at each restart")
+	checkpoint   = flag.String("checkpoint", "optimize_checkpoint.json", "Where to write the state of the search, to be resumed")
+	every        = flag.Int("every", 1, "The number of iterations between checkpoints")
+	resume       = flag.Bool("resume", false, "Whether to continue the search from the checkpoint")
+	quantum      = flag.Float64("quantum", 0.001, "The weights are rounded to multiples of this before being evaluated")
+	manifestFile = flag.String("manifest", "optimize_manifest.json", "Where to write the description of the run")
+	best         = flag.String("best", "optimize_best.json", "Where to write the config of the best strategy found")
+	objective    = flag.String("objective", "relativeCDI", "What the search maximizes: median or relativeCDI")
+	walk         = flag.String("walk", "", "If set to train,test months, the search is also walked forward and its result in the test months printed next to the result in all months")
+	profileName  = flag.String("profile", "retail", "The investor whose investable funds are chosen: retail, qualified or professional")
+	minNumFunds  = flag.Int("minNumFunds", 1, "The least number of funds held")
+	maxNumFunds  = flag.Int("maxNumFunds", 1, "The largest number of funds held")
+	minMaxMonths = flag.Int("minMaxMonths", 60, "The least months the strategy can read")
+	maxMaxMonths = flag.Int("maxMaxMonths", 60, "The most months the strategy can read")
+	minMinMonths = flag.Int("minMinMonths", 120, "The least months the funds need to be chosen")
+	maxMinMonths = flag.Int("maxMinMonths", 120, "The most months the funds need to be chosen")
+)
+
+var rebalance = 0
+
+func main() {
+	flag.Parse()
+	profile, err := xpfunds.ParseProfile(*profileName)
+	check.Check(err)
+	value, err := search.ParseValue(*objective)
+	check.Check(err)
+	var train, test int
+	if *walk != "" {
+		train, test, err = simulate.ParseWalk(*walk)
+		check.Check(err)
+	}
+	source := optimize.NewSource(*seed)
+	runSeed := *seed
+	var resumed *optimize.Checkpoint
+	if *resume {
+		var err error
+		resumed, err = optimize.ReadCheckpoint(*checkpoint)
+		check.Check(err)
+		source = resumed.Source()
+		// The random numbers continue from the seed of the checkpoint.
+		runSeed = resumed.Seed
+	}
+	manifest.New(runSeed, "get.tsv", "delisted.tsv", "cdi.tsv").Write(*manifestFile)
+	rnd := rand.New(source)
+	restart := optimize.FromBest
+	switch *restartFrom {
+	case "best":
+	case "random":
+		restart = optimize.FromRandom
+	default:
+		fmt.Fprintf(os.Stderr, "unknown restart: %v\n", *restartFrom)
+		os.Exit(2)
+	}
+	var optimizer optimize.Optimizer
+	switch *algorithm {
+	case "annealing":
+		optimizer = &optimize.Annealing{Rand: rnd}
+	case "cmaes":
+		optimizer = &optimize.CMAES{Rand: rnd, Restarts: *restarts, Restart: restart, PopulationGrowth: *growth}
+	case "nelderMead":
+		optimizer = &optimize.NelderMead{Rand: rnd, Restarts: *restarts, Restart: restart}
+	case "genetic":
+		optimizer = &optimize.Genetic{Rand: rnd}
+	case "perturb":
+		optimizer = &optimize.Perturb{Rand: rnd}
+	case "shrinking":
+		optimizer = &optimize.Shrinking{}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown algorithm: %v\n", *algorithm)
+		os.Exit(2)
+	}
+	funds := xpfunds.ReadFunds()
+	maxDuration := 0
+	for _, f := range funds {
+		if f.Duration() > maxDuration {
+			maxDuration = f.Duration()
+		}
+	}
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	backtest := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: maxDuration,
+		Rebalance:   rebalance,
+		Cash:        cdi,
+		CDI:         cdi,
+
+		OnlyInvestable: true,
+		Profile:        profile,
+	}
+	space := &search.Space{
+		MinNumFunds:  *minNumFunds,
+		MaxNumFunds:  *maxNumFunds,
+		MinMaxMonths: *minMaxMonths,
+		MaxMaxMonths: *maxMaxMonths,
+		MinMinMonths: *minMinMonths,
+		MaxMinMonths: *maxMinMonths,
+		FeatureCount: funds[0].FeatureCount(),
+	}
+	s := search.NewSearch(space, backtest, *quantum, value)
+	point := make([]float64, space.Dimension())
+	// When resuming, the point is in the checkpoint and the random numbers
+	// were already drawn.
+	if resumed == nil {
+		for i := range point {
+			point[i] = rnd.Float64()*2 - 1
+		}
+	}
+	stop := optimize.Stop{
+		Iterations:    *iterations,
+		Duration:      *duration,
+		NoImprovement: *patience,
+	}
+	r, err := optimizer.Optimize(&optimize.Problem{
+		Objective:       s.Objective,
+		Start:           point,
+		Bounds:          optimize.Weights,
+		Stop:            stop,
+		Checkpoint:      *checkpoint,
+		CheckpointEvery: *every,
+		Source:          source,
+		Resume:          resumed,
+		Progress: func(p *optimize.Progress) {
+			fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", p.Iteration, p.Value, p.Duration.String(), p.Point, p.Step, s)
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	c, err := space.Decode(r.Point)
+	check.Check(err)
+	fmt.Printf("best\t%v\t%v\t%v\t%v\t%v\n", r.Value, c.NumFunds, c.MinMonths, c.Strategy().Name(), c.Weight)
+	config := c.Strategy().Config()
+	config.MinMonths = c.MinMonths
+	check.Check(config.Write(*best))
+	// The best of each number of funds evaluated by this run, which doesn't
+	// include those before resuming.
+	fmt.Println("numFunds\tmedian\tannualReturn\tannualVolatility\tmaxDrawdown\tmaxMonths\tminMonths\tweight")
+	for _, e := range s.Best() {
+		c, r := e.Candidate, e.Result
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", c.NumFunds, r.Median, r.AnnualReturn, r.AnnualVolatility, r.MaxDrawdown, c.MaxMonths, c.MinMonths, c.Weight)
+	}
+	if *walk == "" {
+		return
+	}
+	// The test months are simulated with the largest structure of the space,
+	// so that any candidate can be chosen in them.
+	wb := backtest.Clone()
+	wb.NumFunds = *maxNumFunds
+	wb.MinMonths = *maxMinMonths
+	start := make([]float64, space.Dimension())
+	for i := range start {
+		start[i] = rnd.Float64()*2 - 1
+	}
+	windows, out, err := wb.WalkForward(search.Optimizer(space, optimizer, &optimize.Problem{
+		Start:  start,
+		Bounds: optimize.Weights,
+		Stop:   stop,
+	}, *quantum, value), train, test)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("window\ttrainMedian\ttestMedian\tstrategy")
+	for i, w := range windows {
+		fmt.Printf("%v\t%v\t%v\t%v\n", i, w.Train.Median, w.Test.Median, w.Strategy.Name())
+	}
+	// The best strategy of all months, in the same backtest as the test months.
+	in, err := wb.Run(c.Strategy())
+	check.Check(err)
+	fmt.Println("sample\tmedian\tmean\tworst\tbeatCDI\trelativeCDI")
+	printResult("in", in)
+	printResult("out", out)
+}
+
+func printResult(sample string, r *simulate.Result) {
+	fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", sample, r.Median, r.Mean, r.Worst, r.BeatCDI, r.MedianRelative(simulate.CDI))
+}
@@ -1,93 +1,56 @@
-// This program reads the raw data from XP, get.tsv, and generates 3 files for
-// train and for test. The files for train are:
+// This program reads the raw data from XP, get.tsv, and writes a dataset to
+// train and evaluate supervised models. For each of the train, validation and
+// test splits it writes:
 //
-// 1.  train_data.tsv: A matrix (r x f) with the features used to train the ML.
-// 2.  train_labels.tsv: A vector (r) with the labels used to test the ML, one
-//     label for each line in train_data.tsv.
-// 3.  train_metadata.tsv: A matrix (r x m) containing, for each line in
-//     train_labels.tsv:
-//     a. The name of the fund.
-//     b. The time of the data.
+// 1.  <split>_data: A matrix (r x f) with the features of each row.
+// 2.  <split>_labels: A matrix (r x l) with the labels of each row.
+// 3.  <split>_metadata: The name of the fund and the time of each row.
 //
-// The files for test are analogous.
+// The splits are by date: the test split has the most recent months. A label
+// that would read the months of a later split is NaN.
 
 package main
 
 import (
 	"flag"
 	"fmt"
-	// "github.com/montanaflynn/stats"
-	"os"
+	"strings"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/dataset"
 )
 
-var testMonths = flag.Int("test_months", 1,
-	"How many months to use for test. The rest of the months will be used for"+
-		" trainning. The last months are reserved for testing.")
+var (
+	specFile   = flag.String("spec", "", "A JSON dataset spec, which replaces the flags below")
+	features   = flag.String("features", strings.Join(xpfunds.FeatureNames, ","), "The features, comma separated, from: "+strings.Join(dataset.Registered(), ","))
+	labels     = flag.String("labels", "return6,return12,return24", "The labels, comma separated, each one of "+strings.Join(dataset.LabelKinds, ",")+" followed by the months")
+	months     = flag.Int("months", 12, "How many months the features read, 0 for all")
+	validation = flag.Int("validation", 36, "How many months before the test split are used for validation, longer than the labels to have all of them")
+	testMonths = flag.Int("test_months", 36, "How many of the most recent months are used for test, longer than the labels to have all of them")
+	format     = flag.String("format", "tsv", "The format of the data and labels: "+strings.Join(dataset.Formats, ","))
+	dir        = flag.String("dir", ".", "The directory the files are written to")
+)
 
 func main() {
 	flag.Parse()
-	funds := xpfunds.ReadFunds()
-	duration := xpfunds.MaxDuration(funds)
-	cdi := xpfunds.FundFromFile("cdi.tsv")
-	ipca := xpfunds.FundFromFile("ipca.tsv")
-	writeFiles(funds, cdi, ipca, *testMonths, duration, "train")
-	writeFiles(funds, cdi, ipca, 0, *testMonths, "test")
-}
-
-// Goes through all time periods between end and start (both exclusive) and
-// product the data and labels for this period.
-func writeFiles(funds []*xpfunds.Fund, cdi, ipca *Fund, end, start int, name string) {
-	data, err := os.Create(name + "_data.tsv")
-	xpfunds.Check(err)
-	labels, err := os.Create(name + "_labels.tsv")
-	xpfunds.Check(err)
-	metadata, err := os.Create(name + "_metadata.tsv")
-	xpfunds.Check(err)
-	for _, f := range funds {
-		for time := end + 1; time < start+1; time++ {
-			if time >= f.Duration() {
-				break
-			}
-			// The annualized return from the beginning of the fund until now.
-			fmt.Fprintf(data, "\t%v", f.Annual(time, f.Duration()))
-
-			// Standard deviation.
-			// std, err := stats.StandardDeviation(f.Monthly[time:f.Duration()])
-			// xpfunds.Check(err)
-			// fmt.Fprintf(data, "\t%v", std)
-
-			// The CDI from the month.
-			// fmt.Fprintf(data, "\t%v", cdi.Monthly[time])
-
-			// The IPCA from the month.
-			// fmt.Fprintf(data, "\t%v", ipca.Monthly[time])
-
-			// The return from the last month
-			// fmt.Fprintf(data, "\t%v", f.Annual(time, time+1))
-
-			// An indicator of which fund this data came from.
-			// for _, f2 := range funds {
-			// 	if f.Name == f2.Name {
-			// 		fmt.Fprintf(data, "\t1")
-			// 	} else {
-			// 		fmt.Fprintf(data, "\t0")
-			// 	}
-			// }
-
-			// The number of months from the beginning of the fund until now.
-			// fmt.Fprintf(data, "\t%v", f.Duration() - time)
-
-			fmt.Fprintf(data, "\n")
-			fmt.Fprintf(labels, "%v\n", f.Annual(end, time))
-			fmt.Fprintf(metadata, "%v\t%v\n", f.Name, time)
-		}
+	spec := &dataset.Spec{
+		Features:   strings.Split(*features, ","),
+		Labels:     strings.Split(*labels, ","),
+		Months:     *months,
+		Validation: *validation,
+		Test:       *testMonths,
 	}
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
+	if *specFile != "" {
+		var err error
+		spec, err = dataset.ReadSpec(*specFile)
+		check.Check(err)
+	}
+	spec.CDI = xpfunds.FundFromFile("cdi.tsv")
+	spec.IPCA = xpfunds.FundFromFile("ipca.tsv")
+	d, err := spec.Build(xpfunds.ReadFunds())
+	check.Check(err)
+	check.Check(d.Write(*dir, *format))
+	for _, split := range dataset.Splits {
+		fmt.Printf("%v: %v rows\n", split, len(d.Split(split)))
 	}
-	return b
 }
@@ -1,73 +1,86 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"time"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/manifest"
+	"xpfunds/optimize"
+	"xpfunds/search"
 	"xpfunds/simulate"
 )
 
 var (
-	funds       []*xpfunds.Fund
-	maxDuration int
-	maxMonths   = 3
-	numFunds    = 10
+	manifestFile = flag.String("manifest", "select_manifest.json", "Where to write the description of the run")
+	iterations   = flag.Int("iterations", 0, "The maximum number of iterations, or 0 for no limit")
+	duration     = flag.Duration("duration", 0, "The maximum time running, or 0 for no limit")
+	patience     = flag.Int("patience", 0, "The maximum number of iterations without improvement, or 0 for no limit")
+	checkpoint   = flag.String("checkpoint", "select_checkpoint.json", "Where to write the state of the search, to be resumed")
+	every        = flag.Int("every", 1, "The number of iterations between checkpoints")
+	resume       = flag.Bool("resume", false, "Whether to continue the search from the checkpoint")
+	quantum      = flag.Float64("quantum", 0.001, "The weights are rounded to multiples of this before being evaluated")
+	objective    = flag.String("objective", "relativeCDI", "What the search maximizes: median or relativeCDI")
+	profileName  = flag.String("profile", "retail", "The investor whose investable funds are chosen: retail, qualified or professional")
+	numFunds     = flag.Int("numFunds", 10, "The number of funds held")
+	maxMonths    = flag.Int("maxMonths", 3, "The most months the strategy can read")
+	minMonths    = flag.Int("minMonths", 6, "The least months the funds need to be chosen")
 )
 
+var rebalance = 0
+
 func main() {
-	funds = xpfunds.ReadFunds()
-	for _, f := range funds {
-		if f.Duration() > maxDuration {
-			maxDuration = f.Duration()
-		}
-	}
-	point := make([]float64, (funds[0].FeatureCount()+(&simulate.Weighted{}).FeatureCount())*numFunds)
-	step := 1.0
-	for i := 0; true; i++ {
-		start := time.Now()
-		best, perf := bestInRegion(point, step)
-		end := time.Now()
-		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", i, perf, end.Sub(start).String(), best, step)
-		point = nextPoint(point, best)
-		step /= 2
+	flag.Parse()
+	profile, err := xpfunds.ParseProfile(*profileName)
+	check.Check(err)
+	value, err := search.ParseValue(*objective)
+	check.Check(err)
+	var resumed *optimize.Checkpoint
+	if *resume {
+		var err error
+		resumed, err = optimize.ReadCheckpoint(*checkpoint)
+		check.Check(err)
 	}
-}
+	manifest.New(0, "get.tsv", "delisted.tsv", "cdi.tsv").Write(*manifestFile)
+	funds := xpfunds.ReadFunds()
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	backtest := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		Rebalance:   rebalance,
+		Cash:        cdi,
+		CDI:         cdi,
 
-func bestInRegion(point []float64, step float64) ([]float64, float64) {
-	newPoint := make([]float64, len(point))
-	for i, p := range point {
-		newPoint[i] = p
+		OnlyInvestable: true,
+		Profile:        profile,
 	}
-	bestPerf := simulate.MedianPerformance(funds, maxDuration, maxMonths*2, numFunds, simulate.NewWeighted(maxMonths, newPoint))
-	for i := 0; i < len(newPoint); i++ {
-		newPoint[i] -= step
-		left := simulate.MedianPerformance(funds, maxDuration, maxMonths*2, numFunds, simulate.NewWeighted(maxMonths, newPoint))
-		newPoint[i] += step * 2
-		right := simulate.MedianPerformance(funds, maxDuration, maxMonths*2, numFunds, simulate.NewWeighted(maxMonths, newPoint))
-		// No change.
-		if gt(bestPerf, left) && gt(bestPerf, right) {
-			newPoint[i] -= step
-			continue
-		}
-		if gt(right, left) {
-			bestPerf = right
-			// NewPoint is already at right.
-			continue
-		}
-		bestPerf = left
-		newPoint[i] -= step * 2
+	space := &search.Space{
+		MinNumFunds:  *numFunds,
+		MaxNumFunds:  *numFunds,
+		MinMaxMonths: *maxMonths,
+		MaxMaxMonths: *maxMonths,
+		MinMinMonths: *minMonths,
+		MaxMinMonths: *minMonths,
+		FeatureCount: funds[0].FeatureCount(),
 	}
-	return newPoint, bestPerf
-}
-
-func nextPoint(orig []float64, best []float64) []float64 {
-	new := make([]float64, len(orig))
-	for i := range orig {
-		new[i] = (orig[i] + best[i]) / 2
+	s := search.NewSearch(space, backtest, *quantum, value)
+	_, err = (&optimize.Shrinking{}).Optimize(&optimize.Problem{
+		Objective: s.Objective,
+		Start:     make([]float64, space.Dimension()),
+		Bounds:    optimize.Weights,
+		Stop: optimize.Stop{
+			Iterations:    *iterations,
+			Duration:      *duration,
+			NoImprovement: *patience,
+		},
+		Checkpoint:      *checkpoint,
+		CheckpointEvery: *every,
+		Resume:          resumed,
+		Progress: func(p *optimize.Progress) {
+			fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", p.Iteration, p.Value, p.Duration.String(), p.Point, p.Step, s)
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
 	}
-	return new
-}
-
-func gt(a, b float64) bool {
-	return a-b > 0.000000001
 }
@@ -0,0 +1,339 @@
+// Package dataset exports the features of the funds at each time, with labels
+// from their following months, to train and evaluate supervised models.
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"xpfunds"
+)
+
+// Feature is a value of a fund at a time, known at that time. Value returns
+// false if the fund doesn't have the value at the time.
+type Feature struct {
+	Name  string
+	Value func(s *Spec, f *xpfunds.Fund, time int) (float64, bool)
+}
+
+var registered []*Feature
+
+// Register makes a feature available to the specs by its name.
+func Register(feature *Feature) {
+	registered = append(registered, feature)
+}
+
+// Registered returns the names of the registered features.
+func Registered() []string {
+	var names []string
+	for _, feature := range registered {
+		names = append(names, feature.Name)
+	}
+	return names
+}
+
+func lookup(name string) (*Feature, error) {
+	for _, feature := range registered {
+		if feature.Name == name {
+			return feature, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown feature %v, want one of %v", name, Registered())
+}
+
+func init() {
+	// The features of the fund cube, over the months the spec reads.
+	for i, name := range xpfunds.FeatureNames {
+		i := i
+		Register(&Feature{name, func(s *Spec, f *xpfunds.Fund, time int) (float64, bool) {
+			start := time + s.Months
+			if s.Months == 0 {
+				start = f.Duration()
+			}
+			if !f.Exists(time) || start > f.Duration() || start <= time {
+				return 0, false
+			}
+			return f.Feature(i, time, start), true
+		}})
+	}
+	Register(&Feature{"age", func(s *Spec, f *xpfunds.Fund, time int) (float64, bool) {
+		return float64(f.Duration() - time), f.Exists(time)
+	}})
+	Register(&Feature{"lastMonth", func(s *Spec, f *xpfunds.Fund, time int) (float64, bool) {
+		if !f.Exists(time) {
+			return 0, false
+		}
+		return f.MonthlyReturn(time), true
+	}})
+	Register(&Feature{"cdi", func(s *Spec, f *xpfunds.Fund, time int) (float64, bool) {
+		return market(s.CDI, time)
+	}})
+	Register(&Feature{"ipca", func(s *Spec, f *xpfunds.Fund, time int) (float64, bool) {
+		return market(s.IPCA, time)
+	}})
+}
+
+// market returns the return of an index in the month of time.
+func market(index *xpfunds.Fund, time int) (float64, bool) {
+	if index == nil || !index.Exists(time) {
+		return 0, false
+	}
+	return index.MonthlyReturn(time), true
+}
+
+// LabelKinds are the kinds of labels, followed by their months in the names
+// of the labels, as in "return12".
+var LabelKinds = []string{
+	// The annualized return, as a ratio.
+	"return",
+	// The annualized return minus the annualized return of CDI.
+	"excess",
+	// The position of the return among the funds at the time, from 0, the
+	// worst, to 1, the best.
+	"rank",
+}
+
+type label struct {
+	name   string
+	kind   string
+	months int
+}
+
+func parseLabel(name string) (*label, error) {
+	for _, kind := range LabelKinds {
+		if !strings.HasPrefix(name, kind) {
+			continue
+		}
+		months, err := strconv.Atoi(name[len(kind):])
+		if err != nil || months <= 0 {
+			break
+		}
+		return &label{name, kind, months}, nil
+	}
+	return nil, fmt.Errorf("invalid label %v, want one of %v followed by the months", name, LabelKinds)
+}
+
+// annual returns the annualized return of f in the months after time, and
+// whether f has them.
+func annual(f *xpfunds.Fund, time, months int) (float64, bool) {
+	if f == nil || time-months < 0 || !f.Exists(time-months) {
+		return 0, false
+	}
+	ret := 1.0
+	for t := time - 1; t >= time-months; t-- {
+		ret *= f.MonthlyReturn(t)
+	}
+	return math.Pow(ret, 12/float64(months)), true
+}
+
+// Spec describes a dataset.
+type Spec struct {
+	// The names of the registered features. If empty, xpfunds.FeatureNames.
+	Features []string
+	// The names of the labels.
+	Labels []string
+	// The months read by the features of the fund cube. If 0, all months are
+	// read.
+	Months int
+	// The most recent months are in the test split, the months before them in
+	// the validation split and the rest in the train split.
+	Validation, Test int
+
+	// The indexes read by the features and labels that need them.
+	CDI, IPCA *xpfunds.Fund `json:"-"`
+}
+
+// ReadSpec reads a spec in JSON.
+func ReadSpec(file string) (*Spec, error) {
+	text, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	if err := json.Unmarshal(text, s); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return s, nil
+}
+
+// FeatureNames returns the names of the features of the spec.
+func (s *Spec) FeatureNames() []string {
+	if len(s.Features) == 0 {
+		return xpfunds.FeatureNames
+	}
+	return s.Features
+}
+
+func (s *Spec) features() ([]*Feature, error) {
+	var features []*Feature
+	for _, name := range s.FeatureNames() {
+		feature, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+func (s *Spec) labels() ([]*label, error) {
+	var labels []*label
+	for _, name := range s.Labels {
+		l, err := parseLabel(name)
+		if err != nil {
+			return nil, err
+		}
+		if l.kind == "excess" && s.CDI == nil {
+			return nil, fmt.Errorf("label %v needs CDI", name)
+		}
+		labels = append(labels, l)
+	}
+	return labels, nil
+}
+
+// FeatureValues returns the features of f at time, and whether f has all of
+// them.
+func (s *Spec) FeatureValues(f *xpfunds.Fund, time int) ([]float64, bool, error) {
+	features, err := s.features()
+	if err != nil {
+		return nil, false, err
+	}
+	values, ok := featureValues(s, features, f, time)
+	return values, ok, nil
+}
+
+func featureValues(s *Spec, features []*Feature, f *xpfunds.Fund, time int) ([]float64, bool) {
+	values := make([]float64, len(features))
+	for i, feature := range features {
+		v, ok := feature.Value(s, f, time)
+		if !ok {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// Row is the features and labels of a fund at a time.
+type Row struct {
+	Fund     *xpfunds.Fund
+	Time     int
+	Features []float64
+	Labels   []float64
+}
+
+// Dataset is the rows of each split, ordered by time and fund.
+type Dataset struct {
+	Features, Labels        []string
+	Train, Validation, Test []*Row
+}
+
+// Splits are the names of the splits, from the oldest months.
+var Splits = []string{"train", "validation", "test"}
+
+// Split returns the rows of a split by its name.
+func (d *Dataset) Split(name string) []*Row {
+	switch name {
+	case "train":
+		return d.Train
+	case "validation":
+		return d.Validation
+	case "test":
+		return d.Test
+	}
+	return nil
+}
+
+// Build returns the rows of the funds that have all the features and at least
+// one label. A row is in the split of its time. A label is NaN if the fund
+// doesn't have it or if it reads the months of a later split.
+func (s *Spec) Build(funds []*xpfunds.Fund) (*Dataset, error) {
+	features, err := s.features()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := s.labels()
+	if err != nil {
+		return nil, err
+	}
+	d := &Dataset{Features: s.FeatureNames(), Labels: s.Labels}
+	for time := 0; time < xpfunds.MaxDuration(funds); time++ {
+		split, first := &d.Train, s.Test+s.Validation
+		if time < s.Test {
+			split, first = &d.Test, 0
+		} else if time < s.Test+s.Validation {
+			split, first = &d.Validation, s.Test
+		}
+		*split = append(*split, s.rows(funds, features, labels, time, first)...)
+	}
+	return d, nil
+}
+
+// rows returns the rows of the funds at time, whose labels can't read the
+// months before first.
+func (s *Spec) rows(funds []*xpfunds.Fund, features []*Feature, labels []*label, time, first int) []*Row {
+	var rows []*Row
+	var returns [][]float64
+	for _, f := range funds {
+		values, ok := featureValues(s, features, f, time)
+		if !ok {
+			continue
+		}
+		ret := make([]float64, len(labels))
+		labeled := false
+		for i, l := range labels {
+			ret[i] = math.NaN()
+			if time-l.months < first {
+				continue
+			}
+			if r, ok := annual(f, time, l.months); ok {
+				ret[i] = r
+				labeled = true
+			}
+		}
+		if !labeled {
+			continue
+		}
+		rows = append(rows, &Row{f, time, values, make([]float64, len(labels))})
+		returns = append(returns, ret)
+	}
+	for i, l := range labels {
+		switch l.kind {
+		case "return":
+			for j, r := range rows {
+				r.Labels[i] = returns[j][i]
+			}
+		case "excess":
+			cdi, ok := annual(s.CDI, time, l.months)
+			if !ok {
+				cdi = math.NaN()
+			}
+			for j, r := range rows {
+				r.Labels[i] = returns[j][i] - cdi
+			}
+		case "rank":
+			// Only the funds with the return are ranked.
+			var order []int
+			for j := range rows {
+				rows[j].Labels[i] = math.NaN()
+				if !math.IsNaN(returns[j][i]) {
+					order = append(order, j)
+				}
+			}
+			sort.SliceStable(order, func(a, b int) bool {
+				return returns[order[a]][i] < returns[order[b]][i]
+			})
+			for position, j := range order {
+				rows[j].Labels[i] = 0.5
+				if len(order) > 1 {
+					rows[j].Labels[i] = float64(position) / float64(len(order)-1)
+				}
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,145 @@
+package dataset
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"xpfunds"
+)
+
+func newFunds(monthly ...float64) []*xpfunds.Fund {
+	var funds []*xpfunds.Fund
+	for i, m := range monthly {
+		f := xpfunds.NewFund([]float64{m, m, m, m, m, m, m, m})
+		f.SetName(string(rune('a' + i)))
+		funds = append(funds, f)
+	}
+	return funds
+}
+
+func eq(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) != math.IsNaN(b[i]) || math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func spec() *Spec {
+	return &Spec{
+		Features:   []string{"return", "age"},
+		Labels:     []string{"return1", "excess1", "rank1", "return2"},
+		Months:     2,
+		Validation: 2,
+		Test:       2,
+		CDI:        newFunds(1.01)[0],
+	}
+}
+
+func TestBuild(t *testing.T) {
+	funds := newFunds(1.01, 1.02, 1.0)
+	d, err := spec().Build(funds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The labels of time 4 read time 3, in the validation split, and the
+	// features of time 7 need 2 months.
+	for _, test := range []struct {
+		split string
+		times []int
+	}{
+		{"train", []int{5, 5, 5, 6, 6, 6}},
+		{"validation", []int{3, 3, 3}},
+		{"test", []int{1, 1, 1}},
+	} {
+		var times []int
+		for _, r := range d.Split(test.split) {
+			times = append(times, r.Time)
+		}
+		if len(times) != len(test.times) {
+			t.Errorf("%v: got: %v, want: %v", test.split, times, test.times)
+			continue
+		}
+		for i := range times {
+			if times[i] != test.times[i] {
+				t.Errorf("%v: got: %v, want: %v", test.split, times, test.times)
+				break
+			}
+		}
+	}
+	cdi := math.Pow(1.01, 12)
+	nan := math.NaN()
+	// return2 would read the months of a later split, except at time 6.
+	for _, test := range []struct {
+		got  *Row
+		want *Row
+	}{
+		{d.Test[0], &Row{funds[0], 1, []float64{1.01, 7}, []float64{cdi, 0, 0.5, nan}}},
+		{d.Test[1], &Row{funds[1], 1, []float64{1.02, 7}, []float64{math.Pow(1.02, 12), math.Pow(1.02, 12) - cdi, 1, nan}}},
+		{d.Test[2], &Row{funds[2], 1, []float64{1, 7}, []float64{1, 1 - cdi, 0, nan}}},
+		{d.Validation[0], &Row{funds[0], 3, []float64{1.01, 5}, []float64{cdi, 0, 0.5, nan}}},
+		{d.Train[4], &Row{funds[1], 6, []float64{1.02, 2}, []float64{math.Pow(1.02, 12), math.Pow(1.02, 12) - cdi, 1, math.Pow(1.02, 12)}}},
+	} {
+		got, want := test.got, test.want
+		if got.Fund != want.Fund || got.Time != want.Time || !eq(got.Features, want.Features) || !eq(got.Labels, want.Labels) {
+			t.Errorf("got: %v, want: %v", got, want)
+		}
+	}
+}
+
+func TestSpecErrors(t *testing.T) {
+	for _, s := range []*Spec{
+		{Features: []string{"unknown"}},
+		{Labels: []string{"return"}},
+		{Labels: []string{"return0"}},
+		{Labels: []string{"unknown12"}},
+		{Labels: []string{"excess12"}},
+	} {
+		if _, err := s.Build(newFunds(1)); err == nil {
+			t.Errorf("%v: got: nil, want: error", s)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	funds := newFunds(1.01, 1.02, 1.0)
+	// Names with commas and quotes are quoted in CSV.
+	funds[0].SetName(`a, "A"`)
+	d, err := spec().Build(funds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, format := range Formats {
+		if err := d.Write(dir, format); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for file, want := range map[string]string{
+		"test_data.tsv":       "return\tage\n1.01\t7\n1.02\t7\n1\t7\n",
+		"test_metadata.csv":   "fund,time\n" + `"a, ""A""",1` + "\nb,1\nc,1\n",
+		"validation_data.csv": "return,age\n1.01,5\n1.02,5\n1,5\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%v: got: %q, want: %q", file, got, want)
+		}
+	}
+	npy, err := ioutil.ReadFile(filepath.Join(dir, "test_labels.npy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The header is padded to 64 bytes and followed by 3 rows of 4 labels.
+	if string(npy[:6]) != "\x93NUMPY" || (len(npy)-3*4*8)%64 != 0 {
+		t.Errorf("test_labels.npy: got: %q", npy)
+	}
+}
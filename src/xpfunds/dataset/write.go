@@ -0,0 +1,138 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats are the formats the data and labels can be written in. The
+// metadata is written in CSV if the format is CSV, and in TSV otherwise.
+var Formats = []string{"tsv", "csv", "npy"}
+
+// Write writes three files for each split to dir: <split>_data with the
+// features, <split>_labels with the labels and <split>_metadata with the name
+// of the fund and the time of each row.
+func (d *Dataset) Write(dir, format string) error {
+	var write func(w io.Writer, header []string, values [][]float64) error
+	switch format {
+	case "tsv":
+		write = delimited('\t')
+	case "csv":
+		write = delimited(',')
+	case "npy":
+		write = writeNpy
+	default:
+		return fmt.Errorf("unknown format %v, want one of %v", format, Formats)
+	}
+	metadataFormat, comma := "tsv", '\t'
+	if format == "csv" {
+		metadataFormat, comma = "csv", ','
+	}
+	for _, split := range Splits {
+		rows := d.Split(split)
+		var features, labels [][]float64
+		var metadata [][]string
+		for _, r := range rows {
+			features = append(features, r.Features)
+			labels = append(labels, r.Labels)
+			metadata = append(metadata, []string{r.Fund.Name(), fmt.Sprint(r.Time)})
+		}
+		path := func(name, ext string) string {
+			return filepath.Join(dir, split+"_"+name+"."+ext)
+		}
+		if err := writeFile(path("data", format), func(w io.Writer) error {
+			return write(w, d.Features, features)
+		}); err != nil {
+			return err
+		}
+		if err := writeFile(path("labels", format), func(w io.Writer) error {
+			return write(w, d.Labels, labels)
+		}); err != nil {
+			return err
+		}
+		if err := writeFile(path("metadata", metadataFormat), func(w io.Writer) error {
+			return writeLines(w, comma, append([][]string{{"fund", "time"}}, metadata...))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// delimited writes a header line and a line for each row, with the values
+// separated by comma.
+func delimited(comma rune) func(w io.Writer, header []string, values [][]float64) error {
+	return func(w io.Writer, header []string, values [][]float64) error {
+		lines := [][]string{header}
+		for _, row := range values {
+			var line []string
+			for _, v := range row {
+				line = append(line, fmt.Sprint(v))
+			}
+			lines = append(lines, line)
+		}
+		return writeLines(w, comma, lines)
+	}
+}
+
+// writeLines writes the fields of the lines separated by comma, quoting the
+// fields that contain it.
+func writeLines(w io.Writer, comma rune, lines [][]string) error {
+	c := csv.NewWriter(w)
+	c.Comma = comma
+	if err := c.WriteAll(lines); err != nil {
+		return err
+	}
+	return c.Error()
+}
+
+// writeNpy writes the values as a 2-dimensional NumPy array of float64, in
+// the .npy format version 1.0. The names of the columns are not written.
+func writeNpy(w io.Writer, header []string, values [][]float64) error {
+	dict := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%v, %v), }", len(values), len(header))
+	// The magic string, the version and the length of the header take 10
+	// bytes, and the data must start at a multiple of 64.
+	padding := 64 - (10+len(dict)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	dict += strings.Repeat(" ", padding) + "\n"
+	if _, err := io.WriteString(w, "\x93NUMPY\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(dict))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, dict); err != nil {
+		return err
+	}
+	for _, row := range values {
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
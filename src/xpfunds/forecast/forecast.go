@@ -0,0 +1,292 @@
+// Package forecast fits linear models that predict a label of the funds from
+// their features in a dataset, and chooses the funds by the prediction.
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"xpfunds"
+	"xpfunds/dataset"
+	"xpfunds/simulate"
+)
+
+// Linear predicts a label as the intercept plus the coefficients times the
+// standardized features.
+type Linear struct {
+	Intercept    float64
+	Coefficients []float64
+	// The mean and the standard deviation of each feature in the fit.
+	Mean, StdDev []float64
+}
+
+// ErrSingular is returned when the features don't determine the coefficients,
+// as when a feature is a combination of the others and there's no ridge.
+var ErrSingular = errors.New("singular system")
+
+// labeled returns the rows whose label isn't NaN.
+func labeled(rows []*dataset.Row, label int) []*dataset.Row {
+	var ret []*dataset.Row
+	for _, r := range rows {
+		if !math.IsNaN(r.Labels[label]) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// Fit fits the label of the rows by least squares, plus lambda times the sum
+// of the squared coefficients. If lambda is 0, it's ordinary least squares,
+// otherwise it's ridge regression. The intercept isn't penalized. Rows without
+// the label are ignored.
+func Fit(rows []*dataset.Row, label int, lambda float64) (*Linear, error) {
+	rows = labeled(rows, label)
+	if len(rows) == 0 {
+		return nil, errors.New("no rows to fit")
+	}
+	n := len(rows[0].Features)
+	m := &Linear{Mean: make([]float64, n), StdDev: make([]float64, n)}
+	for _, r := range rows {
+		for i, v := range r.Features {
+			m.Mean[i] += v / float64(len(rows))
+		}
+	}
+	for _, r := range rows {
+		for i, v := range r.Features {
+			m.StdDev[i] += (v - m.Mean[i]) * (v - m.Mean[i]) / float64(len(rows))
+		}
+	}
+	for i := range m.StdDev {
+		m.StdDev[i] = math.Sqrt(m.StdDev[i])
+		// A constant feature is all 0 when standardized.
+		if m.StdDev[i] == 0 {
+			m.StdDev[i] = 1
+		}
+	}
+	// The normal equations, with the intercept as the feature 0, always 1.
+	a := make([][]float64, n+1)
+	for i := range a {
+		a[i] = make([]float64, n+2)
+	}
+	for _, r := range rows {
+		x := append([]float64{1}, m.standardize(r.Features)...)
+		for i := range x {
+			for j := range x {
+				a[i][j] += x[i] * x[j]
+			}
+			a[i][n+1] += x[i] * r.Labels[label]
+		}
+	}
+	for i := 1; i <= n; i++ {
+		a[i][i] += lambda
+	}
+	w, err := solve(a)
+	if err != nil {
+		return nil, err
+	}
+	m.Intercept, m.Coefficients = w[0], w[1:]
+	return m, nil
+}
+
+// solve solves the linear system in the augmented matrix a by Gaussian
+// elimination with partial pivoting.
+func solve(a [][]float64) ([]float64, error) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, ErrSingular
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for j := col; j <= n; j++ {
+				a[row][j] -= factor * a[col][j]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		x[row] = a[row][n]
+		for j := row + 1; j < n; j++ {
+			x[row] -= a[row][j] * x[j]
+		}
+		x[row] /= a[row][row]
+	}
+	return x, nil
+}
+
+func (m *Linear) standardize(features []float64) []float64 {
+	x := make([]float64, len(features))
+	for i, v := range features {
+		x[i] = (v - m.Mean[i]) / m.StdDev[i]
+	}
+	return x
+}
+
+// Predict returns the label predicted for the features.
+func (m *Linear) Predict(features []float64) float64 {
+	y := m.Intercept
+	for i, v := range m.standardize(features) {
+		y += m.Coefficients[i] * v
+	}
+	return y
+}
+
+// Evaluation is how well a model predicts the label of rows it wasn't fit on.
+type Evaluation struct {
+	// 1 minus the squared error over the variance of the label.
+	R2 float64
+	// The mean over the dates of the Spearman correlation between the
+	// predictions and the labels of the funds at the date.
+	RankCorrelation float64
+	// The number of dates with at least 2 funds with different labels and
+	// predictions, which RankCorrelation averages over.
+	Dates int
+}
+
+func (e *Evaluation) String() string {
+	return fmt.Sprintf("R²: %v, rank correlation: %v over %v dates", e.R2, e.RankCorrelation, e.Dates)
+}
+
+// Evaluate evaluates m in the label of the rows. Rows without the label are
+// ignored.
+func Evaluate(m *Linear, rows []*dataset.Row, label int) *Evaluation {
+	rows = labeled(rows, label)
+	e := &Evaluation{R2: math.NaN(), RankCorrelation: math.NaN()}
+	if len(rows) == 0 {
+		return e
+	}
+	mean := 0.0
+	for _, r := range rows {
+		mean += r.Labels[label] / float64(len(rows))
+	}
+	predictions := make([]float64, len(rows))
+	residual, total := 0.0, 0.0
+	byTime := make(map[int][]int)
+	var times []int
+	for i, r := range rows {
+		predictions[i] = m.Predict(r.Features)
+		residual += (r.Labels[label] - predictions[i]) * (r.Labels[label] - predictions[i])
+		total += (r.Labels[label] - mean) * (r.Labels[label] - mean)
+		if _, ok := byTime[r.Time]; !ok {
+			times = append(times, r.Time)
+		}
+		byTime[r.Time] = append(byTime[r.Time], i)
+	}
+	if total > 0 {
+		e.R2 = 1 - residual/total
+	}
+	sum := 0.0
+	for _, time := range times {
+		var predicted, actual []float64
+		for _, i := range byTime[time] {
+			predicted = append(predicted, predictions[i])
+			actual = append(actual, rows[i].Labels[label])
+		}
+		if c, ok := spearman(predicted, actual); ok {
+			sum += c
+			e.Dates++
+		}
+	}
+	if e.Dates > 0 {
+		e.RankCorrelation = sum / float64(e.Dates)
+	}
+	return e
+}
+
+// spearman returns the Spearman rank correlation of a and b, and false if it
+// isn't defined.
+func spearman(a, b []float64) (float64, bool) {
+	return pearson(ranks(a), ranks(b))
+}
+
+// ranks returns the position of each value when sorted, from 0, with ties
+// getting the mean of their positions.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			r[order[k]] = float64(i+j) / 2
+		}
+		i = j + 1
+	}
+	return r
+}
+
+func pearson(a, b []float64) (float64, bool) {
+	if len(a) < 2 {
+		return 0, false
+	}
+	meanA, meanB := 0.0, 0.0
+	for i := range a {
+		meanA += a[i] / float64(len(a))
+		meanB += b[i] / float64(len(b))
+	}
+	cov, varA, varB := 0.0, 0.0, 0.0
+	for i := range a {
+		cov += (a[i] - meanA) * (b[i] - meanB)
+		varA += (a[i] - meanA) * (a[i] - meanA)
+		varB += (b[i] - meanB) * (b[i] - meanB)
+	}
+	if varA == 0 || varB == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varA*varB), true
+}
+
+// Strategy chooses the NumFunds funds with the largest label predicted by
+// Model from the features of Spec. If NumFunds is 0 or less, it chooses all the
+// funds with the features, from the largest prediction.
+type Strategy struct {
+	Model    *Linear
+	Spec     *dataset.Spec
+	NumFunds int
+}
+
+func (s *Strategy) Name() string {
+	return fmt.Sprintf("Forecast(%v,%v,%v)", s.Spec.FeatureNames(), s.Spec.Months, s.NumFunds)
+}
+
+func (s *Strategy) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	predictions := make(map[*xpfunds.Fund]float64)
+	var predicted []*xpfunds.Fund
+	for _, f := range funds {
+		features, ok, err := s.Spec.FeatureValues(f, end)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		predictions[f] = s.Model.Predict(features)
+		predicted = append(predicted, f)
+	}
+	if len(predicted) == 0 {
+		return nil, fmt.Errorf("%v at %v with %v funds: %w", s.Name(), end, len(funds), simulate.ErrNoFund)
+	}
+	sort.SliceStable(predicted, func(i, j int) bool {
+		return predictions[predicted[i]] > predictions[predicted[j]]
+	})
+	if s.NumFunds > 0 && s.NumFunds < len(predicted) {
+		predicted = predicted[:s.NumFunds]
+	}
+	return predicted, nil
+}
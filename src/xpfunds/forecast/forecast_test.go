@@ -0,0 +1,125 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"xpfunds"
+	"xpfunds/dataset"
+	"xpfunds/simulate"
+)
+
+func linearRows() []*dataset.Row {
+	// A row without the label is ignored.
+	rows := []*dataset.Row{{Time: 0, Features: []float64{9, 9}, Labels: []float64{math.NaN()}}}
+	for i, x := range [][]float64{{0, 1}, {1, 0}, {2, 2}, {3, 1}, {4, 5}, {5, 3}} {
+		rows = append(rows, &dataset.Row{
+			Time:     i / 2,
+			Features: x,
+			Labels:   []float64{2 + 3*x[0] - x[1]},
+		})
+	}
+	return rows
+}
+
+func TestFit(t *testing.T) {
+	rows := linearRows()
+	m, err := Fit(rows, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range [][]float64{{0, 0}, {10, -3}, {1.5, 2}} {
+		if got, want := m.Predict(x), 2+3*x[0]-x[1]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%v: got: %v, want: %v", x, got, want)
+		}
+	}
+	ridge, err := Fit(rows, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range m.Coefficients {
+		if math.Abs(ridge.Coefficients[i]) >= math.Abs(m.Coefficients[i]) {
+			t.Errorf("ridge %v: got: %v, want: smaller than %v", i, ridge.Coefficients[i], m.Coefficients[i])
+		}
+	}
+	if math.Abs(ridge.Intercept-m.Intercept) > 1e-9 {
+		t.Errorf("ridge intercept: got: %v, want: %v", ridge.Intercept, m.Intercept)
+	}
+}
+
+func TestFitSingular(t *testing.T) {
+	var rows []*dataset.Row
+	for _, x := range []float64{1, 2, 3} {
+		rows = append(rows, &dataset.Row{Features: []float64{x, 2 * x}, Labels: []float64{x}})
+	}
+	if _, err := Fit(rows, 0, 0); err != ErrSingular {
+		t.Errorf("got: %v, want: %v", err, ErrSingular)
+	}
+	if _, err := Fit(rows, 0, 1); err != nil {
+		t.Errorf("ridge: got: %v, want: nil", err)
+	}
+}
+
+func TestRanks(t *testing.T) {
+	for _, test := range []struct {
+		values, want []float64
+	}{
+		{[]float64{3, 1, 2}, []float64{2, 0, 1}},
+		{[]float64{1, 2, 2, 0}, []float64{1, 2.5, 2.5, 0}},
+	} {
+		got := ranks(test.values)
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%v: got: %v, want: %v", test.values, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rows := linearRows()
+	m, err := Fit(rows, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Evaluate(m, rows, 0)
+	if math.Abs(e.R2-1) > 1e-9 || math.Abs(e.RankCorrelation-1) > 1e-9 || e.Dates != 3 {
+		t.Errorf("perfect: got: %v", e)
+	}
+	reversed := &Linear{Coefficients: []float64{-3, 1}, Mean: m.Mean, StdDev: m.StdDev}
+	if e := Evaluate(reversed, rows, 0); e.R2 >= 0 || math.Abs(e.RankCorrelation+1) > 1e-9 {
+		t.Errorf("reversed: got: %v", e)
+	}
+}
+
+func TestStrategy(t *testing.T) {
+	var funds []*xpfunds.Fund
+	for _, m := range []float64{1.01, 1.03, 1.02} {
+		funds = append(funds, xpfunds.NewFund([]float64{m, m, m}))
+	}
+	spec := &dataset.Spec{Features: []string{"return"}, Months: 2}
+	s := &Strategy{
+		Model:    &Linear{Coefficients: []float64{1}, Mean: []float64{0}, StdDev: []float64{1}},
+		Spec:     spec,
+		NumFunds: 2,
+	}
+	got, err := s.Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != funds[1] || got[1] != funds[2] {
+		t.Errorf("got: %v, want: %v", got, []*xpfunds.Fund{funds[1], funds[2]})
+	}
+	s.NumFunds = 0
+	got, err = s.Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != funds[1] || got[1] != funds[2] || got[2] != funds[0] {
+		t.Errorf("all: got: %v, want: %v", got, []*xpfunds.Fund{funds[1], funds[2], funds[0]})
+	}
+	if _, err := s.Choose(funds, 2); !errors.Is(err, simulate.ErrNoFund) {
+		t.Errorf("got: %v, want: %v", err, simulate.ErrNoFund)
+	}
+}
@@ -0,0 +1,274 @@
+// Package grid evaluates every combination of the parameters of Weighted
+// strategies listed in a spec.
+package grid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"xpfunds"
+	"xpfunds/optimize"
+	"xpfunds/simulate"
+)
+
+// Range is the values from Min to Max, inclusive, separated by Step. If Step
+// is 0, it's only Min.
+type Range struct {
+	Min, Max, Step float64
+}
+
+func (r Range) Values() []float64 {
+	if r.Step == 0 {
+		return []float64{r.Min}
+	}
+	return optimize.Range(r.Min, r.Max, r.Step)
+}
+
+// Spec lists the values of each parameter. The same weights are used for all
+// the funds chosen.
+type Spec struct {
+	// The months monthsToRead and ignoreWithoutMonths are limited to.
+	MaxMonths int
+	// The weights of the features of the funds.
+	Features            []Range
+	MonthsToRead        Range
+	IgnoreWithoutMonths Range
+	MinMonths           Range
+	NumFunds            Range
+	// The number of combinations evaluated at the same time. If 0 or less,
+	// it's the number of CPUs.
+	Workers int
+}
+
+// ReadSpec reads a spec in JSON.
+func ReadSpec(file string) (*Spec, error) {
+	text, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	if err := json.Unmarshal(text, s); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return s, nil
+}
+
+// Validate checks that there's a range for each feature. The values of each
+// point are validated by its config.
+func (s *Spec) Validate() error {
+	if len(s.Features) != len(xpfunds.FeatureNames) {
+		return fmt.Errorf("%v feature ranges, want one for each of %v", len(s.Features), xpfunds.FeatureNames)
+	}
+	return nil
+}
+
+// Point is a combination of the parameters.
+type Point struct {
+	Features            []float64
+	MonthsToRead        int
+	IgnoreWithoutMonths int
+	MinMonths           int
+	NumFunds            int
+}
+
+// Config returns the config of the Weighted strategy of the point, with the
+// same slot for each fund.
+func (p *Point) Config(maxMonths int) *simulate.WeightedConfig {
+	c := &simulate.WeightedConfig{MaxMonths: maxMonths, MinMonths: p.MinMonths}
+	for i := 0; i < p.NumFunds; i++ {
+		slot := &simulate.Slot{
+			Features:            make(map[string]float64),
+			MonthsToRead:        p.MonthsToRead,
+			IgnoreWithoutMonths: p.IgnoreWithoutMonths,
+		}
+		for j, w := range p.Features {
+			if w != 0 {
+				slot.Features[xpfunds.FeatureNames[j]] = w
+			}
+		}
+		c.Slots = append(c.Slots, slot)
+	}
+	return c
+}
+
+// Points returns every combination of the values of the parameters.
+func (s *Spec) Points() []*Point {
+	var ranges []Range
+	ranges = append(ranges, s.Features...)
+	ranges = append(ranges, s.MonthsToRead, s.IgnoreWithoutMonths, s.MinMonths, s.NumFunds)
+	var values [][]float64
+	for _, r := range ranges {
+		values = append(values, r.Values())
+	}
+	var points []*Point
+	combination := make([]float64, len(values))
+	var add func(i int)
+	add = func(i int) {
+		if i == len(values) {
+			n := len(s.Features)
+			points = append(points, &Point{
+				Features:            append([]float64{}, combination[:n]...),
+				MonthsToRead:        int(math.Round(combination[n])),
+				IgnoreWithoutMonths: int(math.Round(combination[n+1])),
+				MinMonths:           int(math.Round(combination[n+2])),
+				NumFunds:            int(math.Round(combination[n+3])),
+			})
+			return
+		}
+		for _, v := range values[i] {
+			combination[i] = v
+			add(i + 1)
+		}
+	}
+	add(0)
+	return points
+}
+
+// Row is the result of a point.
+type Row struct {
+	Point  *Point
+	Result *simulate.Result
+	Err    error
+}
+
+// Run evaluates every point of the spec in b, which gives the other settings.
+// The rows are in the order of the points. A point whose config is invalid has
+// the error in its row.
+func (s *Spec) Run(b *simulate.Backtest) ([]*Row, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	points := s.Points()
+	rows := make([]*Row, len(points))
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				rows[i] = s.run(b, points[i])
+			}
+		}()
+	}
+	for i := range points {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return rows, nil
+}
+
+func (s *Spec) run(b *simulate.Backtest, p *Point) *Row {
+	w, err := p.Config(s.MaxMonths).Weighted()
+	if err != nil {
+		return &Row{p, nil, err}
+	}
+	c := b.Clone()
+	c.MinMonths = p.MinMonths
+	c.NumFunds = p.NumFunds
+	// The points are already evaluated in parallel.
+	c.Workers = 1
+	r, err := c.Run(w)
+	return &Row{p, r, err}
+}
+
+// Columns are the statistics the rows can be sorted by.
+var Columns = []string{"median", "mean", "worst", "beatCDI", "relativeCDI", "annualReturn", "annualVolatility", "maxDrawdown"}
+
+func (r *Row) value(column string) float64 {
+	if r.Err != nil {
+		return math.Inf(-1)
+	}
+	switch column {
+	case "median":
+		return r.Result.Median
+	case "mean":
+		return r.Result.Mean
+	case "worst":
+		return r.Result.Worst
+	case "beatCDI":
+		return r.Result.BeatCDI
+	case "relativeCDI":
+		return r.Result.MedianRelative(simulate.CDI)
+	case "annualReturn":
+		return r.Result.AnnualReturn
+	case "annualVolatility":
+		return r.Result.AnnualVolatility
+	case "maxDrawdown":
+		return r.Result.MaxDrawdown
+	}
+	return math.NaN()
+}
+
+// Sort sorts rows by column, from the largest value. Rows with errors are
+// last.
+func Sort(rows []*Row, column string) error {
+	if !isColumn(column) {
+		return fmt.Errorf("unknown column %v, want one of %v", column, Columns)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].value(column) > rows[j].value(column)
+	})
+	return nil
+}
+
+func isColumn(column string) bool {
+	for _, c := range Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTSV writes the rows as a table with a header.
+func WriteTSV(w io.Writer, rows []*Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	header := append([]string{}, xpfunds.FeatureNames...)
+	header = append(header, "monthsToRead", "ignoreWithoutMonths", "minMonths", "numFunds")
+	header = append(header, Columns...)
+	header = append(header, "error")
+	if _, err := fmt.Fprintln(w, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		var fields []string
+		for _, f := range r.Point.Features {
+			fields = append(fields, fmt.Sprint(f))
+		}
+		p := r.Point
+		fields = append(fields, fmt.Sprint(p.MonthsToRead), fmt.Sprint(p.IgnoreWithoutMonths), fmt.Sprint(p.MinMonths), fmt.Sprint(p.NumFunds))
+		for _, c := range Columns {
+			if r.Err != nil {
+				fields = append(fields, "")
+				continue
+			}
+			fields = append(fields, fmt.Sprint(r.value(c)))
+		}
+		e := ""
+		if r.Err != nil {
+			e = r.Err.Error()
+		}
+		fields = append(fields, e)
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package grid
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"xpfunds"
+	"xpfunds/simulate"
+)
+
+func TestPoints(t *testing.T) {
+	s := &Spec{
+		MaxMonths:    4,
+		Features:     []Range{{-1, 1, 1}, {0.5, 0.5, 0}},
+		MonthsToRead: Range{0, 4, 2},
+		NumFunds:     Range{1, 2, 1},
+	}
+	points := s.Points()
+	if got, want := len(points), 3*1*3*2; got != want {
+		t.Fatalf("len: got: %v, want: %v", got, want)
+	}
+	first, last := points[0], points[len(points)-1]
+	if !eqSlice(first.Features, []float64{-1, 0.5}) || first.MonthsToRead != 0 || first.NumFunds != 1 {
+		t.Errorf("first: got: %+v", first)
+	}
+	if !eqSlice(last.Features, []float64{1, 0.5}) || last.MonthsToRead != 4 || last.NumFunds != 2 {
+		t.Errorf("last: got: %+v", last)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	features := make([]float64, len(xpfunds.FeatureNames))
+	features[0], features[1] = 0.5, -0.5
+	p := &Point{Features: features, MonthsToRead: 3, IgnoreWithoutMonths: 0, NumFunds: 2}
+	w, err := p.Config(4).Weighted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	weight := append(append([]float64{}, features...), 0.5, -1)
+	want := simulate.NewWeighted(4, append(weight, weight...))
+	if w.Name() != want.Name() {
+		t.Errorf("got: %v, want: %v", w.Name(), want.Name())
+	}
+	// Weights out of the range of the config are errors.
+	p.Features[0] = 2
+	if _, err := p.Config(4).Weighted(); err == nil {
+		t.Errorf("weight 2: got: nil, want: error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := &Spec{Features: []Range{{0, 0, 0}}}
+	if err := s.Validate(); err == nil {
+		t.Errorf("got: nil, want: error")
+	}
+	if _, err := s.Run(&simulate.Backtest{}); err == nil {
+		t.Errorf("Run: got: nil, want: error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	funds := []*xpfunds.Fund{
+		xpfunds.NewFund([]float64{1.1, 0.9, 1.2, 1, 1.3, 0.8}),
+		xpfunds.NewFund([]float64{1, 1.1, 0.9, 1.2, 1, 1.1}),
+		xpfunds.NewFund([]float64{1.05, 1, 1.05, 1, 1.05, 1}),
+	}
+	xpfunds.SetRatio(funds)
+	b := &simulate.Backtest{Funds: funds, MaxDuration: 6}
+	features := make([]Range, funds[0].FeatureCount())
+	features[0] = Range{-1, 1, 2}
+	s := &Spec{Features: features, NumFunds: Range{0, 2, 1}, Workers: 2}
+	rows, err := s.Run(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 6; got != want {
+		t.Fatalf("len: got: %v, want: %v", got, want)
+	}
+	for i, r := range rows {
+		// A config without slots is invalid.
+		if r.Point.NumFunds == 0 {
+			if r.Err == nil {
+				t.Errorf("%v: got: nil, want: error", i)
+			}
+			continue
+		}
+		c := b.Clone()
+		c.NumFunds = r.Point.NumFunds
+		w, err := r.Point.Config(0).Weighted()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := c.MedianPerformance(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Err != nil || math.Abs(r.Result.Median-want) > 1e-9 {
+			t.Errorf("%v: got: %v (%v), want: %v", i, r.Result, r.Err, want)
+		}
+	}
+	if err := Sort(rows, "median"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(rows); i++ {
+		if rows[i].value("median") > rows[i-1].value("median") {
+			t.Errorf("%v: not sorted: %v after %v", i, rows[i].value("median"), rows[i-1].value("median"))
+		}
+	}
+	if rows[len(rows)-1].Err == nil {
+		t.Errorf("rows with errors should be last")
+	}
+	if err := Sort(rows, "unknown"); err == nil {
+		t.Errorf("unknown column: got: nil, want: an error")
+	}
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), len(rows)+1; got != want {
+		t.Errorf("lines: got: %v, want: %v", got, want)
+	}
+	for _, line := range lines {
+		if got, want := len(strings.Split(line, "\t")), len(features)+4+len(Columns)+1; got != want {
+			t.Errorf("%q: got: %v fields, want: %v", line, got, want)
+		}
+	}
+}
+
+func eqSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// Package manifest describes the runs of the programs, so that their results
+// can be reproduced.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+	"xpfunds/check"
+)
+
+type Manifest struct {
+	Program string
+	Args    []string
+
+	// The value of each flag, including the ones not set in Args.
+	Flags map[string]string
+
+	// The seed of the random numbers.
+	Seed int64
+
+	// The SHA-256 of each input file that exists.
+	Inputs map[string]string
+
+	// The git commit of the code, followed by "-dirty" if there were
+	// uncommitted changes.
+	Version string
+
+	Start time.Time
+}
+
+// New describes the current run. It must be called after flag.Parse.
+func New(seed int64, inputs ...string) *Manifest {
+	m := &Manifest{
+		Program: path.Base(os.Args[0]),
+		Args:    os.Args[1:],
+		Flags:   make(map[string]string),
+		Seed:    seed,
+		Inputs:  make(map[string]string),
+		Version: version(),
+		Start:   time.Now(),
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		m.Flags[f.Name] = f.Value.String()
+	})
+	for _, input := range inputs {
+		text, err := ioutil.ReadFile(input)
+		if os.IsNotExist(err) {
+			continue
+		}
+		check.Check(err)
+		m.Inputs[input] = fmt.Sprintf("%x", sha256.Sum256(text))
+	}
+	return m
+}
+
+func version() string {
+	commit, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	v := strings.TrimSpace(string(commit))
+	status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil || len(status) > 0 {
+		v += "-dirty"
+	}
+	return v
+}
+
+// Write writes m as JSON to file.
+func (m *Manifest) Write(file string) {
+	text, err := json.MarshalIndent(m, "", "  ")
+	check.Check(err)
+	check.Check(ioutil.WriteFile(file, append(text, '\n'), 0644))
+}
+
+// Read reads a manifest written by Write.
+func Read(file string) *Manifest {
+	text, err := ioutil.ReadFile(file)
+	check.Check(err)
+	m := &Manifest{}
+	check.Check(json.Unmarshal(text, m))
+	return m
+}
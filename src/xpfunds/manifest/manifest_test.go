@@ -0,0 +1,44 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := path.Join(dir, "input.tsv")
+	if err := ioutil.WriteFile(input, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := path.Join(dir, "missing.tsv")
+
+	m := New(42, input, missing)
+	if got, want := m.Seed, int64(42); got != want {
+		t.Errorf("Seed: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Inputs[input], fmt.Sprintf("%x", sha256.Sum256([]byte("data"))); got != want {
+		t.Errorf("Inputs[input]: got: %v, want: %v", got, want)
+	}
+	if _, ok := m.Inputs[missing]; ok {
+		t.Errorf("Inputs has missing file")
+	}
+	if m.Version == "" {
+		t.Errorf("Version is empty")
+	}
+
+	file := path.Join(dir, "manifest.json")
+	m.Write(file)
+	read := Read(file)
+	if read.Seed != m.Seed || read.Inputs[input] != m.Inputs[input] || read.Version != m.Version || !read.Start.Equal(m.Start) {
+		t.Errorf("Read: got: %+v, want: %+v", read, m)
+	}
+}
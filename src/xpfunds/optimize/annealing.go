@@ -0,0 +1,72 @@
+package optimize
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Annealing moves to random neighbours of the point, also accepting worse
+// ones with a probability that falls as the temperature cools, so that it can
+// leave local optima.
+type Annealing struct {
+	// If nil, the random numbers come from the Source of the problem, or from
+	// seed 1.
+	Rand *rand.Rand
+	// The initial temperature, in units of the objective. If 0, it is 0.001.
+	Temperature float64
+	// What the temperature is multiplied by after each iteration. If 0, it is
+	// 0.99.
+	Cooling float64
+	// The standard deviation of the moves of each coordinate at the initial
+	// temperature. The moves shrink with the temperature. If 0, it is 0.5.
+	Step float64
+}
+
+func (a *Annealing) Name() string {
+	return "annealing"
+}
+
+type annealingState struct {
+	Point       []float64
+	Temperature float64
+	Step        float64
+}
+
+func (a *Annealing) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	rnd := r.random(a.Rand)
+	state := &annealingState{
+		Point:       copyPoint(p.Start),
+		Temperature: orDefault(a.Temperature, 0.001),
+		Step:        orDefault(a.Step, 0.5),
+	}
+	if _, err := r.resume(a.Name(), state); err != nil {
+		return nil, err
+	}
+	cooling := orDefault(a.Cooling, 0.99)
+	value := r.evaluate(state.Point)
+	for !r.done() {
+		neighbour := copyPoint(state.Point)
+		for i := range neighbour {
+			neighbour[i] = p.Bounds.Clamp(neighbour[i] + rnd.NormFloat64()*state.Step)
+		}
+		newValue := r.evaluate(neighbour)
+		if newValue >= value || (!math.IsInf(newValue, -1) && rnd.Float64() < math.Exp((newValue-value)/state.Temperature)) {
+			state.Point, value = neighbour, newValue
+		}
+		r.iterate(state.Point, value, state.Step)
+		state.Temperature *= cooling
+		state.Step *= cooling
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
+
+func orDefault(x, def float64) float64 {
+	if x == 0 {
+		return def
+	}
+	return x
+}
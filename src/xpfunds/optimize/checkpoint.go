@@ -0,0 +1,165 @@
+package optimize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Checkpoint is the state of an optimization, from which it can be resumed.
+type Checkpoint struct {
+	Optimizer        string
+	Iteration        int
+	SinceImprovement int
+	Elapsed          time.Duration
+	// The number of coordinates of the points.
+	Dimension int
+	// The best point found so far and its value. If there's no best point,
+	// the value is meaningless.
+	Best      []float64
+	BestValue float64
+	// The state of the random numbers, if there's a Source.
+	Seed  int64
+	Draws uint64
+	// What the optimizer needs to continue, such as its current point and
+	// step.
+	State json.RawMessage
+}
+
+// ReadCheckpoint reads a checkpoint written by an optimization.
+func ReadCheckpoint(file string) (*Checkpoint, error) {
+	text, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(text, c); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return c, nil
+}
+
+// Source returns the source of random numbers in the state it was when the
+// checkpoint was written.
+func (c *Checkpoint) Source() *Source {
+	s := NewSource(c.Seed)
+	for s.draws < c.Draws {
+		s.Uint64()
+	}
+	return s
+}
+
+func (c *Checkpoint) write(file string) error {
+	text, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Writing to another file first keeps the last checkpoint if the program
+	// stops while writing.
+	if err := ioutil.WriteFile(file+".tmp", append(text, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// Source is a source of random numbers that counts how many were drawn, so
+// that its state can be saved.
+type Source struct {
+	seed  int64
+	draws uint64
+	src   rand.Source64
+}
+
+func NewSource(seed int64) *Source {
+	return &Source{seed: seed, src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *Source) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *Source) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *Source) Seed(seed int64) {
+	s.seed = seed
+	s.draws = 0
+	s.src.Seed(seed)
+}
+
+// resume sets state, the variables an optimizer needs to continue, from the
+// checkpoint being resumed, if any. state is kept to be saved in the
+// checkpoints. It returns whether the optimization was resumed.
+func (r *run) resume(optimizer string, state interface{}) (bool, error) {
+	r.optimizer = optimizer
+	r.state = state
+	c := r.problem.Resume
+	if c == nil {
+		return false, nil
+	}
+	if c.Optimizer != optimizer {
+		return false, fmt.Errorf("checkpoint of %v, not %v", c.Optimizer, optimizer)
+	}
+	if c.Dimension != r.dimension || (c.Best != nil && len(c.Best) != r.dimension) {
+		return false, fmt.Errorf("checkpoint with %v coordinates, not %v", c.Dimension, r.dimension)
+	}
+	if err := json.Unmarshal(c.State, state); err != nil {
+		return false, err
+	}
+	r.iteration = c.Iteration
+	r.sinceImprovement = c.SinceImprovement
+	r.start = time.Now().Add(-c.Elapsed)
+	if c.Best != nil {
+		r.best = copyPoint(c.Best)
+		r.bestValue = c.BestValue
+	}
+	return true, nil
+}
+
+// checkpoint writes the state of the optimization, if it's time to.
+func (r *run) checkpoint() error {
+	every := r.problem.CheckpointEvery
+	if every == 0 {
+		every = 1
+	}
+	if r.iteration%every != 0 {
+		return nil
+	}
+	return r.save()
+}
+
+// save writes the state of the optimization, if there's a checkpoint file.
+func (r *run) save() error {
+	p := r.problem
+	if p.Checkpoint == "" || r.state == nil {
+		return nil
+	}
+	state, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	c := &Checkpoint{
+		Optimizer:        r.optimizer,
+		Iteration:        r.iteration,
+		SinceImprovement: r.sinceImprovement,
+		Elapsed:          time.Since(r.start),
+		Dimension:        r.dimension,
+		State:            state,
+	}
+	if r.best != nil && !math.IsInf(r.bestValue, 0) {
+		c.Best = r.best
+		c.BestValue = r.bestValue
+	}
+	if p.Source != nil {
+		c.Seed = p.Source.seed
+		c.Draws = p.Source.draws
+	}
+	return c.write(p.Checkpoint)
+}
@@ -0,0 +1,134 @@
+package optimize
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	optimizers := map[string]func(r *rand.Rand) Optimizer{
+		"shrinking": func(*rand.Rand) Optimizer { return &Shrinking{} },
+		"perturb":   func(r *rand.Rand) Optimizer { return &Perturb{Rand: r} },
+		"grid": func(*rand.Rand) Optimizer {
+			values := Range(-0.5, 1, 0.5)
+			return &Grid{Values: [][]float64{values, values, values}}
+		},
+		"annealing": func(r *rand.Rand) Optimizer { return &Annealing{Rand: r} },
+		"genetic":   func(r *rand.Rand) Optimizer { return &Genetic{Rand: r, Population: 6} },
+		"nelderMead": func(r *rand.Rand) Optimizer {
+			return &NelderMead{Rand: r, Restarts: 10, Restart: FromRandom, TolX: 0.01}
+		},
+		"cmaes": func(r *rand.Rand) Optimizer { return &CMAES{Rand: r, Restarts: 10, Restart: FromRandom, TolX: 0.01} },
+	}
+	for name, newOptimizer := range optimizers {
+		file := filepath.Join(t.TempDir(), "checkpoint.json")
+		problem := func(iterations int, source *Source, resume *Checkpoint, points *[][]float64) *Problem {
+			return &Problem{
+				Objective:  quadratic([]float64{0.3, -0.6, 0.1}),
+				Start:      []float64{0, 0, 0},
+				Bounds:     Weights,
+				Stop:       Stop{Iterations: iterations},
+				Progress:   func(p *Progress) { *points = append(*points, p.Point) },
+				Checkpoint: file,
+				Source:     source,
+				Resume:     resume,
+			}
+		}
+		var want [][]float64
+		source := NewSource(1)
+		if _, err := newOptimizer(rand.New(source)).Optimize(problem(40, source, nil, &want)); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		var got [][]float64
+		source = NewSource(1)
+		if _, err := newOptimizer(rand.New(source)).Optimize(problem(15, source, nil, &got)); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		c, err := ReadCheckpoint(file)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if c.Iteration != 15 {
+			t.Errorf("%v: iteration: got: %v, want: %v", name, c.Iteration, 15)
+		}
+		source = c.Source()
+		r, err := newOptimizer(rand.New(source)).Optimize(problem(40, source, c, &got))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%v: got: %v iterations, want: %v", name, len(got), len(want))
+		}
+		for i := range want {
+			if !eqSlice(got[i], want[i]) {
+				t.Errorf("%v: iteration %v: got: %v, want: %v", name, i, got[i], want[i])
+				break
+			}
+		}
+		if r.Iterations != 40 {
+			t.Errorf("%v: iterations: got: %v, want: %v", name, r.Iterations, 40)
+		}
+	}
+}
+
+func TestResumeOtherOptimizer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "checkpoint.json")
+	p := &Problem{
+		Objective:  quadratic([]float64{0, 0}),
+		Start:      []float64{0.5, 0.5},
+		Bounds:     Weights,
+		Stop:       Stop{Iterations: 2},
+		Checkpoint: file,
+	}
+	if _, err := (&Shrinking{}).Optimize(p); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Resume = c
+	if _, err := (&Perturb{Rand: rand.New(NewSource(1))}).Optimize(p); err == nil {
+		t.Errorf("got: nil, want: an error")
+	}
+}
+
+func TestResumeOtherDimension(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "checkpoint.json")
+	p := &Problem{
+		Objective:  quadratic([]float64{0, 0, 0}),
+		Start:      []float64{0.5, 0.5},
+		Bounds:     Weights,
+		Stop:       Stop{Iterations: 2},
+		Checkpoint: file,
+	}
+	if _, err := (&Shrinking{}).Optimize(p); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start = []float64{0.5, 0.5, 0.5}
+	p.Resume = c
+	if _, err := (&Shrinking{}).Optimize(p); err == nil {
+		t.Errorf("got: nil, want: an error")
+	}
+}
+
+func TestSource(t *testing.T) {
+	source := NewSource(3)
+	r := rand.New(source)
+	for i := 0; i < 10; i++ {
+		r.Float64()
+		r.NormFloat64()
+		r.Intn(7)
+	}
+	c := &Checkpoint{Seed: 3, Draws: source.draws}
+	want := r.Float64()
+	if got := rand.New(c.Source()).Float64(); got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
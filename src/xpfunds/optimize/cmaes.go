@@ -0,0 +1,216 @@
+package optimize
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// CMAES samples each generation from a normal distribution around a mean,
+// adapting the mean, the step size and the covariance to the best samples.
+// Samples outside the bounds are moved to them. When the distribution
+// converges, it restarts, optionally with a larger population.
+type CMAES struct {
+	// If nil, the random numbers come from the Source of the problem, or from
+	// seed 1.
+	Rand *rand.Rand
+	// The initial step size. If 0, it is 0.3 of the bounds.
+	Sigma float64
+	// The number of samples per generation. If 0, it is 4+3ln(n) for n
+	// coordinates.
+	Population int
+	// The distribution has converged when the step in all coordinates is
+	// below TolX or the values of a generation are within TolFun. If 0, they
+	// are 1e-6 and 1e-12.
+	TolX, TolFun float64
+	// The maximum number of restarts after converging. After them, the
+	// optimization ends at the next convergence.
+	Restarts int
+	Restart  Restart
+	// What the population is multiplied by at each restart. If 0, it is 1.
+	PopulationGrowth float64
+}
+
+func (c *CMAES) Name() string {
+	return "cmaes"
+}
+
+type cmaesState struct {
+	Restarts   int
+	Population int
+	// The generation since the last restart.
+	Generation int
+	Mean       []float64
+	Sigma      float64
+	// The evolution paths of the covariance and of sigma.
+	Pc, Ps     []float64
+	Covariance [][]float64
+}
+
+// reset starts the distribution again around mean.
+func (c *CMAES) reset(s *cmaesState, mean []float64, bounds Bounds) {
+	n := len(mean)
+	s.Generation = 0
+	s.Mean = copyPoint(mean)
+	s.Sigma = orDefault(c.Sigma, 0.3*(bounds.Max-bounds.Min))
+	s.Pc = make([]float64, n)
+	s.Ps = make([]float64, n)
+	s.Covariance = identity(n)
+}
+
+func (c *CMAES) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	rnd := r.random(c.Rand)
+	state := &cmaesState{Population: c.Population}
+	if state.Population == 0 {
+		state.Population = 4 + int(3*math.Log(float64(len(p.Start))))
+	}
+	c.reset(state, p.Start, p.Bounds)
+	if _, err := r.resume(c.Name(), state); err != nil {
+		return nil, err
+	}
+	growth := orDefault(c.PopulationGrowth, 1)
+	for !r.done() {
+		if c.generation(r, state, rnd) {
+			if state.Restarts == c.Restarts {
+				break
+			}
+			state.Restarts++
+			state.Population = int(float64(state.Population) * growth)
+			c.reset(state, c.Restart.point(r, rnd), p.Bounds)
+		}
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
+
+// generation samples a generation and adapts the distribution to it. It
+// returns whether the distribution converged.
+func (c *CMAES) generation(r *run, s *cmaesState, rnd *rand.Rand) bool {
+	bounds := r.problem.Bounds
+	n := len(s.Mean)
+	nf := float64(n)
+	lambda := s.Population
+	tolX := orDefault(c.TolX, 1e-6)
+	tolFun := orDefault(c.TolFun, 1e-12)
+	mu := lambda / 2
+	if mu < 1 {
+		mu = 1
+	}
+	weights := make([]float64, mu)
+	sum := 0.0
+	for i := range weights {
+		weights[i] = math.Log(float64(mu)+0.5) - math.Log(float64(i+1))
+		sum += weights[i]
+	}
+	sumSq := 0.0
+	for i := range weights {
+		weights[i] /= sum
+		sumSq += weights[i] * weights[i]
+	}
+	mueff := 1 / sumSq
+	cc := (4 + mueff/nf) / (nf + 4 + 2*mueff/nf)
+	cs := (mueff + 2) / (nf + mueff + 5)
+	c1 := 2 / ((nf+1.3)*(nf+1.3) + mueff)
+	cmu := math.Min(1-c1, 2*(mueff-2+1/mueff)/((nf+2)*(nf+2)+mueff))
+	damps := 1 + 2*math.Max(0, math.Sqrt((mueff-1)/(nf+1))-1) + cs
+	chiN := math.Sqrt(nf) * (1 - 1/(4*nf) + 1/(21*nf*nf))
+
+	// The covariance is B D^2 B'.
+	values, b := eigen(s.Covariance)
+	d := make([]float64, n)
+	for i, v := range values {
+		d[i] = math.Sqrt(math.Max(v, 1e-20))
+	}
+	samples := make([]*individual, lambda)
+	for k := range samples {
+		z := make([]float64, n)
+		for i := range z {
+			z[i] = rnd.NormFloat64() * d[i]
+		}
+		point := make([]float64, n)
+		for i := range point {
+			y := 0.0
+			for j := range z {
+				y += b[i][j] * z[j]
+			}
+			point[i] = bounds.Clamp(s.Mean[i] + s.Sigma*y)
+		}
+		samples[k] = &individual{point, r.evaluate(point)}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].value > samples[j].value
+	})
+	r.iterate(samples[0].point, samples[0].value, s.Sigma)
+	s.Generation++
+
+	old := s.Mean
+	s.Mean = make([]float64, n)
+	for k, w := range weights {
+		for i := range s.Mean {
+			s.Mean[i] += w * samples[k].point[i]
+		}
+	}
+	ymean := make([]float64, n)
+	for i := range ymean {
+		ymean[i] = (s.Mean[i] - old[i]) / s.Sigma
+	}
+	// C^-1/2 * ymean = B D^-1 B' ymean.
+	bty := make([]float64, n)
+	for j := range bty {
+		for i := range ymean {
+			bty[j] += b[i][j] * ymean[i]
+		}
+		bty[j] /= d[j]
+	}
+	psNorm := 0.0
+	for i := range s.Ps {
+		invSqrt := 0.0
+		for j := range bty {
+			invSqrt += b[i][j] * bty[j]
+		}
+		s.Ps[i] = (1-cs)*s.Ps[i] + math.Sqrt(cs*(2-cs)*mueff)*invSqrt
+		psNorm += s.Ps[i] * s.Ps[i]
+	}
+	psNorm = math.Sqrt(psNorm)
+	hsig := 0.0
+	if psNorm/math.Sqrt(1-math.Pow(1-cs, 2*float64(s.Generation)))/chiN < 1.4+2/(nf+1) {
+		hsig = 1
+	}
+	for i := range s.Pc {
+		s.Pc[i] = (1-cc)*s.Pc[i] + hsig*math.Sqrt(cc*(2-cc)*mueff)*ymean[i]
+	}
+	cov := s.Covariance
+	for i := range cov {
+		for j := 0; j <= i; j++ {
+			rankMu := 0.0
+			for k, w := range weights {
+				rankMu += w * (samples[k].point[i] - old[i]) * (samples[k].point[j] - old[j])
+			}
+			rankMu /= s.Sigma * s.Sigma
+			cov[i][j] = (1-c1-cmu)*cov[i][j] +
+				c1*(s.Pc[i]*s.Pc[j]+(1-hsig)*cc*(2-cc)*cov[i][j]) +
+				cmu*rankMu
+			cov[j][i] = cov[i][j]
+		}
+	}
+	s.Sigma *= math.Exp(cs / damps * (psNorm/chiN - 1))
+
+	maxD := 0.0
+	for _, v := range values {
+		maxD = math.Max(maxD, math.Sqrt(math.Max(v, 0)))
+	}
+	best, worst := samples[0].value, samples[len(samples)-1].value
+	return s.Sigma*maxD < tolX || (!math.IsInf(worst, -1) && best-worst <= tolFun)
+}
+
+func identity(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	return m
+}
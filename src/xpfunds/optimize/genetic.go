@@ -0,0 +1,125 @@
+package optimize
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Genetic evolves a population of points, one generation per iteration. The
+// best points are kept and the others are replaced by children of points
+// selected by tournament, whose coordinates are taken from either parent and
+// then mutated.
+type Genetic struct {
+	// If nil, the random numbers come from the Source of the problem, or from
+	// seed 1.
+	Rand *rand.Rand
+	// The number of points in each generation. If 0, it is 20.
+	Population int
+	// The number of best points kept in the next generation. If 0, it is 2.
+	Elite int
+	// The probability of mutating each coordinate of a child. If 0, it is 0.1.
+	MutationRate float64
+	// The standard deviation of the mutations. If 0, it is 0.25.
+	MutationStep float64
+	// The number of points competing to be a parent. If 0, it is 2.
+	Tournament int
+}
+
+func (g *Genetic) Name() string {
+	return "genetic"
+}
+
+type individual struct {
+	point []float64
+	value float64
+}
+
+type geneticState struct {
+	Population [][]float64
+}
+
+func (g *Genetic) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	rnd := r.random(g.Rand)
+	size := g.Population
+	if size == 0 {
+		size = 20
+	}
+	elite := g.Elite
+	if elite == 0 {
+		elite = 2
+	}
+	if elite > size {
+		elite = size
+	}
+	rate := orDefault(g.MutationRate, 0.1)
+	step := orDefault(g.MutationStep, 0.25)
+	state := &geneticState{}
+	resumed, err := r.resume(g.Name(), state)
+	if err != nil {
+		return nil, err
+	}
+	if resumed && len(state.Population) != size {
+		return nil, fmt.Errorf("checkpoint with population %v, not %v", len(state.Population), size)
+	}
+	if !resumed {
+		state.Population = [][]float64{copyPoint(p.Start)}
+		for len(state.Population) < size {
+			point := make([]float64, len(p.Start))
+			for i := range point {
+				point[i] = p.Bounds.Min + rnd.Float64()*(p.Bounds.Max-p.Bounds.Min)
+			}
+			state.Population = append(state.Population, point)
+		}
+	}
+	var population []*individual
+	for _, point := range state.Population {
+		population = append(population, &individual{point, r.evaluate(point)})
+	}
+	for !r.done() {
+		sort.SliceStable(population, func(i, j int) bool {
+			return population[i].value > population[j].value
+		})
+		r.iterate(population[0].point, population[0].value, step)
+		next := append([]*individual{}, population[:elite]...)
+		for len(next) < size {
+			a, b := g.parent(population, rnd), g.parent(population, rnd)
+			child := make([]float64, len(a.point))
+			for i := range child {
+				child[i] = a.point[i]
+				if rnd.Intn(2) == 1 {
+					child[i] = b.point[i]
+				}
+				if rnd.Float64() < rate {
+					child[i] = p.Bounds.Clamp(child[i] + rnd.NormFloat64()*step)
+				}
+			}
+			next = append(next, &individual{child, r.evaluate(child)})
+		}
+		population = next
+		for i, ind := range population {
+			state.Population[i] = ind.point
+		}
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
+
+// parent returns the best of random points of the population.
+func (g *Genetic) parent(population []*individual, rnd *rand.Rand) *individual {
+	tournament := g.Tournament
+	if tournament == 0 {
+		tournament = 2
+	}
+	var best *individual
+	for i := 0; i < tournament; i++ {
+		ind := population[rnd.Intn(len(population))]
+		if best == nil || ind.value > best.value {
+			best = ind
+		}
+	}
+	return best
+}
@@ -0,0 +1,83 @@
+package optimize
+
+import "fmt"
+
+// Grid evaluates every combination of values of the coordinates, one per
+// iteration.
+type Grid struct {
+	// The values of each coordinate. If nil, they are the values from the
+	// minimum to the maximum of the bounds, separated by Step, which must be
+	// positive.
+	Values [][]float64
+	Step   float64
+}
+
+func (g *Grid) Name() string {
+	return "grid"
+}
+
+// Range returns the values from min to max, inclusive, separated by step. If
+// step isn't positive, it's only min.
+func Range(min, max, step float64) []float64 {
+	if step <= 0 {
+		return []float64{min}
+	}
+	var values []float64
+	// Counting the values avoids accumulating rounding errors.
+	for i := 0; min+float64(i)*step <= max+step/1e6; i++ {
+		values = append(values, min+float64(i)*step)
+	}
+	return values
+}
+
+type gridState struct {
+	// The index of the value of each coordinate of the next point.
+	Indexes []int
+	// Whether all points were evaluated.
+	Done bool
+}
+
+func (g *Grid) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	values := g.Values
+	if values == nil {
+		if g.Step <= 0 {
+			return nil, fmt.Errorf("grid step %v without values, want positive", g.Step)
+		}
+		for range p.Start {
+			values = append(values, Range(p.Bounds.Min, p.Bounds.Max, g.Step))
+		}
+	}
+	r.dimension = len(values)
+	state := &gridState{Indexes: make([]int, len(values))}
+	if _, err := r.resume(g.Name(), state); err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if len(v) == 0 {
+			state.Done = true
+		}
+	}
+	indexes := state.Indexes
+	point := make([]float64, len(values))
+	for !state.Done && !r.done() {
+		for i, index := range indexes {
+			point[i] = values[i][index]
+		}
+		r.iterate(point, r.evaluate(point), g.Step)
+		// Advances to the next combination like an odometer.
+		i := len(indexes) - 1
+		for ; i >= 0; i-- {
+			indexes[i]++
+			if indexes[i] < len(values[i]) {
+				break
+			}
+			indexes[i] = 0
+		}
+		state.Done = i < 0
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
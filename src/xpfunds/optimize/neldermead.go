@@ -0,0 +1,177 @@
+package optimize
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// NelderMead moves a simplex of points towards the best, reflecting,
+// expanding and contracting it. When the simplex converges, it restarts.
+type NelderMead struct {
+	// Used to restart from random points, as when no point was feasible. If
+	// nil, the random numbers come from the Source of the
+	// problem, or from seed 1.
+	Rand *rand.Rand
+	// The distance from the initial point to the others of the simplex. If 0,
+	// it is 0.5.
+	Step float64
+	// The simplex has converged when all points are closer than TolX to the
+	// best or their values are within TolFun. If 0, they are 1e-6 and 1e-12.
+	TolX, TolFun float64
+	// The maximum number of restarts after converging. After them, the
+	// optimization ends at the next convergence.
+	Restarts int
+	Restart  Restart
+}
+
+func (nm *NelderMead) Name() string {
+	return "nelderMead"
+}
+
+type nelderMeadState struct {
+	Simplex  [][]float64
+	Restarts int
+}
+
+func (s *nelderMeadState) setSimplex(simplex []*individual) {
+	s.Simplex = nil
+	for _, ind := range simplex {
+		s.Simplex = append(s.Simplex, ind.point)
+	}
+}
+
+func (nm *NelderMead) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	rnd := r.random(nm.Rand)
+	step := orDefault(nm.Step, 0.5)
+	tolX := orDefault(nm.TolX, 1e-6)
+	tolFun := orDefault(nm.TolFun, 1e-12)
+	state := &nelderMeadState{}
+	resumed, err := r.resume(nm.Name(), state)
+	if err != nil {
+		return nil, err
+	}
+	var simplex []*individual
+	if resumed {
+		for _, point := range state.Simplex {
+			simplex = append(simplex, &individual{point, r.evaluate(point)})
+		}
+	} else {
+		simplex = nm.simplex(r, p.Start, step)
+		state.setSimplex(simplex)
+	}
+	for !r.done() {
+		sort.SliceStable(simplex, func(i, j int) bool {
+			return simplex[i].value > simplex[j].value
+		})
+		if nm.converged(simplex, tolX, tolFun) {
+			if state.Restarts == nm.Restarts {
+				break
+			}
+			state.Restarts++
+			simplex = nm.simplex(r, nm.Restart.point(r, rnd), step)
+			state.setSimplex(simplex)
+			continue
+		}
+		nm.step(r, simplex)
+		r.iterate(simplex[0].point, simplex[0].value, diameter(simplex))
+		state.setSimplex(simplex)
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
+
+// simplex returns the initial simplex around start.
+func (nm *NelderMead) simplex(r *run, start []float64, step float64) []*individual {
+	simplex := []*individual{{copyPoint(start), r.evaluate(start)}}
+	for i := range start {
+		point := copyPoint(start)
+		point[i] += step
+		if !r.problem.Bounds.Contains(point[i]) {
+			point[i] -= 2 * step
+		}
+		point[i] = r.problem.Bounds.Clamp(point[i])
+		simplex = append(simplex, &individual{point, r.evaluate(point)})
+	}
+	return simplex
+}
+
+func (nm *NelderMead) converged(simplex []*individual, tolX, tolFun float64) bool {
+	if diameter(simplex) < tolX {
+		return true
+	}
+	best, worst := simplex[0].value, simplex[len(simplex)-1].value
+	return !math.IsInf(worst, -1) && best-worst <= tolFun
+}
+
+// step replaces the worst point of the sorted simplex or shrinks it towards
+// the best.
+func (nm *NelderMead) step(r *run, simplex []*individual) {
+	n := len(simplex) - 1
+	centroid := make([]float64, len(simplex[0].point))
+	for _, ind := range simplex[:n] {
+		for i, x := range ind.point {
+			centroid[i] += x / float64(n)
+		}
+	}
+	worst := simplex[n]
+	// The point from the centroid in the direction of the worst, scaled.
+	towards := func(scale float64) *individual {
+		point := make([]float64, len(centroid))
+		for i, c := range centroid {
+			point[i] = r.problem.Bounds.Clamp(c + scale*(worst.point[i]-c))
+		}
+		return &individual{point, r.evaluate(point)}
+	}
+	reflected := towards(-1)
+	switch {
+	case reflected.value > simplex[0].value:
+		if expanded := towards(-2); expanded.value > reflected.value {
+			simplex[n] = expanded
+		} else {
+			simplex[n] = reflected
+		}
+		return
+	case reflected.value > simplex[n-1].value:
+		simplex[n] = reflected
+		return
+	case reflected.value > worst.value:
+		if contracted := towards(-0.5); contracted.value >= reflected.value {
+			simplex[n] = contracted
+			return
+		}
+	default:
+		if contracted := towards(0.5); contracted.value > worst.value {
+			simplex[n] = contracted
+			return
+		}
+	}
+	best := simplex[0].point
+	for _, ind := range simplex[1:] {
+		for i := range ind.point {
+			ind.point[i] = best[i] + 0.5*(ind.point[i]-best[i])
+		}
+		ind.value = r.evaluate(ind.point)
+	}
+}
+
+// diameter returns the largest distance from the first point of the simplex
+// to the others.
+func diameter(simplex []*individual) float64 {
+	d := 0.0
+	for _, ind := range simplex[1:] {
+		d = math.Max(d, distance(simplex[0].point, ind.point))
+	}
+	return d
+}
+
+func distance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,201 @@
+// Package optimize searches for the point that maximizes an objective, such as
+// the weights of a strategy with the best performance.
+package optimize
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Objective returns the value of point, which is to be maximized. Points that
+// are not feasible return an error and are considered worse than any other.
+type Objective func(point []float64) (float64, error)
+
+// ErrInfeasible is returned when no feasible point was found.
+var ErrInfeasible = errors.New("no feasible point found")
+
+// Bounds are the limits, inclusive, of each coordinate of the points.
+type Bounds struct {
+	Min, Max float64
+}
+
+// Weights are the bounds of the weights of simulate.Weighted.
+var Weights = Bounds{-1, 1}
+
+func (b Bounds) Contains(x float64) bool {
+	return x >= b.Min && x <= b.Max
+}
+
+func (b Bounds) Clamp(x float64) float64 {
+	return math.Max(b.Min, math.Min(b.Max, x))
+}
+
+// Stop says when an optimization ends. Zero values mean no limit, so the zero
+// Stop runs until the optimizer finishes, which may be never.
+type Stop struct {
+	// The maximum number of iterations.
+	Iterations int
+	// The maximum time running.
+	Duration time.Duration
+	// The maximum number of iterations in a row without improving the best
+	// value.
+	NoImprovement int
+}
+
+// Progress describes an iteration of an optimization.
+type Progress struct {
+	Iteration int
+	// The point reached by the iteration and its value.
+	Point []float64
+	Value float64
+	// The best point found so far and its value.
+	Best      []float64
+	BestValue float64
+	// How far the optimizer is looking from the point, if it applies.
+	Step     float64
+	Duration time.Duration
+}
+
+// Problem is what is optimized.
+type Problem struct {
+	Objective Objective
+	// The initial point, which also defines the number of coordinates.
+	Start  []float64
+	Bounds Bounds
+	Stop   Stop
+	// If set, called after each iteration.
+	Progress func(*Progress)
+	// If set, the state of the optimization is written to this file every
+	// CheckpointEvery iterations, or every iteration if it's 0, and when it
+	// ends.
+	Checkpoint      string
+	CheckpointEvery int
+	// The source of the random numbers of the optimizer, whose state is saved
+	// in the checkpoints.
+	Source *Source
+	// If set, the optimization continues from this checkpoint instead of
+	// Start. The optimizer must be the same that wrote it.
+	Resume *Checkpoint
+}
+
+// Result is the best point found by an optimization.
+type Result struct {
+	Point      []float64
+	Value      float64
+	Iterations int
+}
+
+type Optimizer interface {
+	Name() string
+	Optimize(p *Problem) (*Result, error)
+}
+
+// run keeps the state shared by the optimizers while they optimize a problem.
+type run struct {
+	problem          *Problem
+	start            time.Time
+	iterationStart   time.Time
+	iteration        int
+	sinceImprovement int
+	best             []float64
+	bestValue        float64
+	// The number of coordinates of the points, checked when resuming.
+	dimension int
+	// The name of the optimizer and the variables it needs to continue, to
+	// be saved in checkpoints.
+	optimizer string
+	state     interface{}
+}
+
+func newRun(p *Problem) *run {
+	now := time.Now()
+	return &run{
+		problem:        p,
+		start:          now,
+		iterationStart: now,
+		bestValue:      math.Inf(-1),
+		dimension:      len(p.Start),
+	}
+}
+
+// random returns rnd or, if it's nil, random numbers from the source of the
+// problem, or from seed 1 if there's none.
+func (r *run) random(rnd *rand.Rand) *rand.Rand {
+	if rnd != nil {
+		return rnd
+	}
+	if r.problem.Source != nil {
+		return rand.New(r.problem.Source)
+	}
+	return rand.New(NewSource(1))
+}
+
+// evaluate returns the value of point, which is -Inf if it is not feasible or
+// out of bounds.
+func (r *run) evaluate(point []float64) float64 {
+	for _, x := range point {
+		if !r.problem.Bounds.Contains(x) {
+			return math.Inf(-1)
+		}
+	}
+	value, err := r.problem.Objective(point)
+	if err != nil || math.IsNaN(value) {
+		return math.Inf(-1)
+	}
+	if value > r.bestValue {
+		r.best = copyPoint(point)
+		r.bestValue = value
+		r.sinceImprovement = -1
+	}
+	return value
+}
+
+// iterate ends an iteration that reached point with value.
+func (r *run) iterate(point []float64, value, step float64) {
+	r.iteration++
+	r.sinceImprovement++
+	now := time.Now()
+	if r.problem.Progress != nil {
+		r.problem.Progress(&Progress{
+			Iteration: r.iteration - 1,
+			Point:     copyPoint(point),
+			Value:     value,
+			Best:      copyPoint(r.best),
+			BestValue: r.bestValue,
+			Step:      step,
+			Duration:  now.Sub(r.iterationStart),
+		})
+	}
+	r.iterationStart = now
+}
+
+func (r *run) done() bool {
+	stop := r.problem.Stop
+	return (stop.Iterations > 0 && r.iteration >= stop.Iterations) ||
+		(stop.Duration > 0 && time.Since(r.start) >= stop.Duration) ||
+		(stop.NoImprovement > 0 && r.sinceImprovement >= stop.NoImprovement)
+}
+
+func (r *run) result() (*Result, error) {
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	if r.best == nil {
+		return nil, ErrInfeasible
+	}
+	return &Result{r.best, r.bestValue, r.iteration}, nil
+}
+
+func copyPoint(point []float64) []float64 {
+	if point == nil {
+		return nil
+	}
+	return append([]float64{}, point...)
+}
+
+// gt says whether a is greater than b by more than rounding errors.
+func gt(a, b float64) bool {
+	return a-b > 0.000000001
+}
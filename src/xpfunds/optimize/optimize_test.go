@@ -0,0 +1,281 @@
+package optimize
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// The objective with the maximum 0 at the target. The points whose first
+// coordinate is below -0.9 are infeasible.
+func quadratic(target []float64) Objective {
+	return func(point []float64) (float64, error) {
+		if point[0] < -0.9 {
+			return 0, errors.New("infeasible")
+		}
+		value := 0.0
+		for i, x := range point {
+			value -= (x - target[i]) * (x - target[i])
+		}
+		return value, nil
+	}
+}
+
+func TestOptimizers(t *testing.T) {
+	target := []float64{0.5, -0.25, 0.75}
+	tests := []struct {
+		optimizer Optimizer
+		stop      Stop
+		maxDist   float64
+	}{
+		{&Shrinking{}, Stop{Iterations: 30}, 1e-3},
+		{&Perturb{Rand: rand.New(rand.NewSource(1))}, Stop{Iterations: 500}, 0.1},
+		{&Grid{Step: 0.25}, Stop{}, 1e-9},
+		{&Annealing{Rand: rand.New(rand.NewSource(1)), Temperature: 0.01, Cooling: 0.995}, Stop{Iterations: 2000}, 0.1},
+		{&Genetic{Rand: rand.New(rand.NewSource(1))}, Stop{Iterations: 100}, 0.1},
+		{&NelderMead{}, Stop{Iterations: 200}, 1e-3},
+		{&CMAES{Rand: rand.New(rand.NewSource(1))}, Stop{Iterations: 200}, 1e-3},
+	}
+	for _, test := range tests {
+		p := &Problem{
+			Objective: quadratic(target),
+			Start:     []float64{0, 0, 0},
+			Bounds:    Weights,
+			Stop:      test.stop,
+		}
+		r, err := test.optimizer.Optimize(p)
+		if err != nil {
+			t.Fatalf("%v: %v", test.optimizer.Name(), err)
+		}
+		if dist := math.Sqrt(-r.Value); dist > test.maxDist {
+			t.Errorf("%v: got: %v at %v, want: within %v of %v", test.optimizer.Name(), r.Value, r.Point, test.maxDist, target)
+		}
+		for _, x := range r.Point {
+			if !Weights.Contains(x) {
+				t.Errorf("%v: %v out of bounds", test.optimizer.Name(), r.Point)
+			}
+		}
+	}
+}
+
+func TestGeneticElitism(t *testing.T) {
+	var values []float64
+	p := &Problem{
+		Objective: quadratic([]float64{0.5, -0.5}),
+		Start:     []float64{0, 0},
+		Bounds:    Weights,
+		Stop:      Stop{Iterations: 20},
+		Progress:  func(pr *Progress) { values = append(values, pr.Value) },
+	}
+	if _, err := (&Genetic{Rand: rand.New(rand.NewSource(1)), Population: 5, Elite: 1}).Optimize(p); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i] < values[i-1] {
+			t.Errorf("generation %v: got: %v, want: at least %v", i, values[i], values[i-1])
+		}
+	}
+}
+
+func TestRestarts(t *testing.T) {
+	// A plateau converges at once, so each iteration follows a restart.
+	plateau := func([]float64) (float64, error) { return 1, nil }
+	tests := []struct {
+		optimizer Optimizer
+		want      int
+	}{
+		{&NelderMead{Rand: rand.New(rand.NewSource(1)), Restarts: 3, Restart: FromRandom}, 0},
+		{&CMAES{Rand: rand.New(rand.NewSource(1)), Restarts: 3, Restart: FromRandom}, 4},
+	}
+	for _, test := range tests {
+		p := &Problem{
+			Objective: plateau,
+			Start:     []float64{0, 0},
+			Bounds:    Weights,
+			Stop:      Stop{Iterations: 100},
+		}
+		r, err := test.optimizer.Optimize(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Iterations != test.want {
+			t.Errorf("%v: got: %v, want: %v", test.optimizer.Name(), r.Iterations, test.want)
+		}
+	}
+}
+
+func TestEigen(t *testing.T) {
+	a := [][]float64{
+		{4, 1, 0},
+		{1, 3, 1},
+		{0, 1, 2},
+	}
+	values, vectors := eigen(a)
+	for k, value := range values {
+		for i := range a {
+			got := 0.0
+			for j := range a {
+				got += a[i][j] * vectors[j][k]
+			}
+			if want := value * vectors[i][k]; math.Abs(got-want) > 1e-9 {
+				t.Errorf("%v, %v: got: %v, want: %v", k, i, got, want)
+			}
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	constant := func([]float64) (float64, error) { return 1, nil }
+	tests := []struct {
+		name string
+		stop Stop
+		want int
+	}{
+		{"iterations", Stop{Iterations: 5}, 5},
+		{"noImprovement", Stop{NoImprovement: 3}, 4},
+		{"both", Stop{Iterations: 2, NoImprovement: 3}, 2},
+	}
+	for _, test := range tests {
+		var progress []int
+		p := &Problem{
+			Objective: constant,
+			Start:     []float64{0},
+			Bounds:    Weights,
+			Stop:      test.stop,
+			Progress:  func(pr *Progress) { progress = append(progress, pr.Iteration) },
+		}
+		r, err := (&Shrinking{}).Optimize(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Iterations != test.want {
+			t.Errorf("%v: got: %v, want: %v", test.name, r.Iterations, test.want)
+		}
+		if len(progress) != test.want || progress[len(progress)-1] != test.want-1 {
+			t.Errorf("%v: progress: got: %v, want: %v iterations", test.name, progress, test.want)
+		}
+	}
+}
+
+func TestStopDuration(t *testing.T) {
+	slow := func([]float64) (float64, error) {
+		time.Sleep(time.Millisecond)
+		return 1, nil
+	}
+	p := &Problem{
+		Objective: slow,
+		Start:     []float64{0},
+		Bounds:    Weights,
+		Stop:      Stop{Duration: 20 * time.Millisecond},
+	}
+	start := time.Now()
+	if _, err := (&Shrinking{}).Optimize(p); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("got: %v, want: about %v", elapsed, p.Stop.Duration)
+	}
+}
+
+func TestInfeasible(t *testing.T) {
+	p := &Problem{
+		Objective: func([]float64) (float64, error) { return 0, errors.New("infeasible") },
+		Start:     []float64{0},
+		Bounds:    Weights,
+		Stop:      Stop{Iterations: 3},
+	}
+	for _, optimizer := range []Optimizer{
+		&Shrinking{},
+		&NelderMead{Restarts: 2},
+		&CMAES{Restarts: 2},
+		&Annealing{},
+		&Perturb{},
+		&Genetic{},
+	} {
+		if _, err := optimizer.Optimize(p); err != ErrInfeasible {
+			t.Errorf("%v: got: %v, want: %v", optimizer.Name(), err, ErrInfeasible)
+		}
+	}
+}
+
+func TestShrinkingInfeasible(t *testing.T) {
+	var points [][]float64
+	p := &Problem{
+		Objective: func([]float64) (float64, error) { return 0, errors.New("infeasible") },
+		Start:     []float64{0.5},
+		Bounds:    Weights,
+		Stop:      Stop{Iterations: 3},
+		Progress:  func(pr *Progress) { points = append(points, pr.Point) },
+	}
+	(&Shrinking{}).Optimize(p)
+	for _, point := range points {
+		if point[0] != 0.5 {
+			t.Errorf("got: %v, want: %v", point, p.Start)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	for _, test := range []struct {
+		min, max, step float64
+		want           []float64
+	}{
+		{-1, 1, 0.5, []float64{-1, -0.5, 0, 0.5, 1}},
+		{-1, 1, 0, []float64{-1}},
+		{-1, 1, -0.5, []float64{-1}},
+	} {
+		if got := Range(test.min, test.max, test.step); !eqSlice(got, test.want) {
+			t.Errorf("%v, %v, %v: got: %v, want: %v", test.min, test.max, test.step, got, test.want)
+		}
+	}
+}
+
+func TestGridStep(t *testing.T) {
+	p := &Problem{
+		Objective: func(point []float64) (float64, error) { return point[0], nil },
+		Start:     []float64{0},
+		Bounds:    Weights,
+	}
+	if _, err := (&Grid{}).Optimize(p); err == nil {
+		t.Errorf("got: nil, want: error")
+	}
+}
+
+func TestGridValues(t *testing.T) {
+	var points [][]float64
+	p := &Problem{
+		Objective: func(point []float64) (float64, error) { return point[0] + point[1], nil },
+		Bounds:    Weights,
+		Progress:  func(pr *Progress) { points = append(points, pr.Point) },
+	}
+	r, err := (&Grid{Values: [][]float64{{0, 1}, {-1, 0.5}}}).Optimize(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{0, -1}, {0, 0.5}, {1, -1}, {1, 0.5}}
+	if len(points) != len(want) {
+		t.Fatalf("got: %v, want: %v", points, want)
+	}
+	for i := range want {
+		if !eqSlice(points[i], want[i]) {
+			t.Errorf("%v: got: %v, want: %v", i, points[i], want[i])
+		}
+	}
+	if !eqSlice(r.Point, []float64{1, 0.5}) {
+		t.Errorf("best: got: %v, want: %v", r.Point, []float64{1, 0.5})
+	}
+}
+
+func eqSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
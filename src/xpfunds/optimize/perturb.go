@@ -0,0 +1,54 @@
+package optimize
+
+import (
+	"math/rand"
+)
+
+// Perturb moves each coordinate by a random step, keeping the moves that
+// improve the value.
+type Perturb struct {
+	// If nil, the random numbers come from the Source of the problem, or from
+	// seed 1.
+	Rand *rand.Rand
+	// The largest step. If 0, it is 1.
+	Step float64
+}
+
+func (pe *Perturb) Name() string {
+	return "perturb"
+}
+
+type perturbState struct {
+	Point []float64
+}
+
+func (pe *Perturb) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	rnd := r.random(pe.Rand)
+	maxStep := orDefault(pe.Step, 1)
+	state := &perturbState{copyPoint(p.Start)}
+	if _, err := r.resume(pe.Name(), state); err != nil {
+		return nil, err
+	}
+	point := state.Point
+	for !r.done() {
+		value := r.evaluate(point)
+		for i := range point {
+			step := (rnd.Float64()*2 - 1) * maxStep
+			if !p.Bounds.Contains(point[i] + step) {
+				continue
+			}
+			point[i] += step
+			if newValue := r.evaluate(point); newValue > value {
+				value = newValue
+				continue
+			}
+			point[i] -= step
+		}
+		r.iterate(point, value, maxStep)
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
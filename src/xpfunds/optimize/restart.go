@@ -0,0 +1,28 @@
+package optimize
+
+import (
+	"math/rand"
+)
+
+// Restart says where an optimizer starts again after converging.
+type Restart int
+
+const (
+	// From the best point found so far, or from a random point if none was
+	// feasible.
+	FromBest Restart = iota
+	// From a random point within the bounds.
+	FromRandom
+)
+
+// point returns where to restart.
+func (re Restart) point(r *run, rnd *rand.Rand) []float64 {
+	if re == FromRandom || r.best == nil {
+		point := make([]float64, len(r.problem.Start))
+		for i := range point {
+			point[i] = r.problem.Bounds.Min + rnd.Float64()*(r.problem.Bounds.Max-r.problem.Bounds.Min)
+		}
+		return point
+	}
+	return copyPoint(r.best)
+}
@@ -0,0 +1,63 @@
+package optimize
+
+import "math"
+
+// Shrinking moves each coordinate by a step to whichever side improves the
+// value, then moves halfway to the point found and halves the step.
+type Shrinking struct {
+	// The initial step. If 0, it is 1.
+	Step float64
+}
+
+func (s *Shrinking) Name() string {
+	return "shrinking"
+}
+
+type shrinkingState struct {
+	Point []float64
+	Step  float64
+}
+
+func (s *Shrinking) Optimize(p *Problem) (*Result, error) {
+	r := newRun(p)
+	state := &shrinkingState{copyPoint(p.Start), orDefault(s.Step, 1)}
+	if _, err := r.resume(s.Name(), state); err != nil {
+		return nil, err
+	}
+	for !r.done() {
+		best, value := s.bestInRegion(r, state.Point, state.Step)
+		r.iterate(best, value, state.Step)
+		for i := range state.Point {
+			state.Point[i] = (state.Point[i] + best[i]) / 2
+		}
+		state.Step /= 2
+		if err := r.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.result()
+}
+
+func (s *Shrinking) bestInRegion(r *run, point []float64, step float64) ([]float64, float64) {
+	newPoint := copyPoint(point)
+	bestValue := r.evaluate(newPoint)
+	for i := range newPoint {
+		newPoint[i] -= step
+		left := r.evaluate(newPoint)
+		newPoint[i] += step * 2
+		right := r.evaluate(newPoint)
+		// No change, also when neither side is feasible.
+		if gt(bestValue, left) && gt(bestValue, right) || math.IsInf(math.Max(left, right), -1) {
+			newPoint[i] -= step
+			continue
+		}
+		if gt(right, left) {
+			bestValue = right
+			// NewPoint is already at right.
+			continue
+		}
+		bestValue = left
+		newPoint[i] -= step * 2
+	}
+	return newPoint, bestValue
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"xpfunds/optimize"
+	"xpfunds/simulate"
+)
+
+// Optimizer returns a simulate.Optimizer that searches space in each backtest
+// with optimizer, maximizing value. The problem gives everything but the
+// objective, and its checkpoints are ignored, so each backtest is searched
+// from the start. The strategy found is evaluated with the NumFunds and
+// MinMonths of the backtest walked forward, not those of its candidate.
+func Optimizer(space *Space, optimizer optimize.Optimizer, problem *optimize.Problem, quantum float64, value Value) simulate.Optimizer {
+	return func(b *simulate.Backtest) (simulate.Strategy, error) {
+		s := NewSearch(space, b, quantum, value)
+		p := *problem
+		p.Objective = s.Objective
+		p.Checkpoint = ""
+		p.Resume = nil
+		r, err := optimizer.Optimize(&p)
+		if err != nil {
+			return nil, err
+		}
+		c, err := space.Decode(r.Point)
+		if err != nil {
+			return nil, err
+		}
+		return c.Strategy(), nil
+	}
+}
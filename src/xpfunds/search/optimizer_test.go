@@ -0,0 +1,61 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"xpfunds"
+	"xpfunds/optimize"
+	"xpfunds/simulate"
+)
+
+func TestOptimizer(t *testing.T) {
+	funds := []*xpfunds.Fund{
+		xpfunds.NewFund([]float64{1.1, 0.9, 1.2, 1, 1.3, 0.8, 1, 1.1, 1.2, 0.9}),
+		xpfunds.NewFund([]float64{1, 1.1, 0.9, 1.2, 1, 1.1, 1.2, 0.9, 1, 1.1}),
+		xpfunds.NewFund([]float64{1.05, 1, 1.05, 1, 1.05, 1, 1.05, 1, 1.05, 1}),
+	}
+	xpfunds.SetRatio(funds)
+	b := &simulate.Backtest{Funds: funds, MaxDuration: 10, NumFunds: 1}
+	space := &Space{MinNumFunds: 1, MaxNumFunds: 1, FeatureCount: funds[0].FeatureCount()}
+	var trains []*simulate.Backtest
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	problem := &optimize.Problem{
+		Start:      make([]float64, space.Dimension()),
+		Bounds:     optimize.Weights,
+		Stop:       optimize.Stop{Iterations: 2},
+		Checkpoint: checkpoint,
+	}
+	opt := Optimizer(space, &optimize.Shrinking{}, problem, 0, nil)
+	windows, r, err := b.WalkForward(func(train *simulate.Backtest) (simulate.Strategy, error) {
+		trains = append(trains, train)
+		return opt(train)
+	}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) == 0 || len(r.Dates) == 0 {
+		t.Fatalf("got: %v windows, %v test dates, want some", len(windows), len(r.Dates))
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("checkpoint written: %v", err)
+	}
+	for i, w := range windows {
+		// The strategy is the best the search finds in the train backtest.
+		s := NewSearch(space, trains[i], 0, nil)
+		p := *problem
+		p.Objective = s.Objective
+		p.Checkpoint = ""
+		best, err := (&optimize.Shrinking{}).Optimize(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := space.Decode(best.Point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := w.Strategy.Name(), c.Strategy().Name(); got != want {
+			t.Errorf("window %v: got: %v, want: %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package search decodes the points of the optimizers into Weighted
+// strategies together with the structure of the portfolio: how many funds it
+// holds and how many months the strategies read and need.
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"xpfunds/simulate"
+)
+
+// Space is the values the structural parameters can take, inclusive. The
+// first coordinates of a point are the number of funds, maxMonths and
+// minMonths; the others are the weights of Weighted for up to MaxNumFunds
+// funds, of which only those of the funds held are used.
+type Space struct {
+	MinNumFunds, MaxNumFunds   int
+	MinMaxMonths, MaxMaxMonths int
+	MinMinMonths, MaxMinMonths int
+	// The number of features of the funds.
+	FeatureCount int
+}
+
+// Candidate is a point decoded.
+type Candidate struct {
+	NumFunds  int
+	MaxMonths int
+	MinMonths int
+	Weight    []float64
+}
+
+func (c *Candidate) Strategy() *simulate.Weighted {
+	return simulate.NewWeighted(c.MaxMonths, c.Weight)
+}
+
+// Dimension returns the number of coordinates of the points.
+func (s *Space) Dimension() int {
+	return 3 + s.MaxNumFunds*s.slotSize()
+}
+
+func (s *Space) slotSize() int {
+	return s.FeatureCount + (&simulate.Weighted{}).FeatureCount()
+}
+
+// Decode returns the candidate of point, whose coordinates are in [-1, 1]. It
+// returns an error if point doesn't have the dimension of s.
+func (s *Space) Decode(point []float64) (*Candidate, error) {
+	if len(point) != s.Dimension() {
+		return nil, fmt.Errorf("point with %v coordinates, want %v", len(point), s.Dimension())
+	}
+	c := &Candidate{
+		NumFunds:  between(point[0], s.MinNumFunds, s.MaxNumFunds),
+		MaxMonths: between(point[1], s.MinMaxMonths, s.MaxMaxMonths),
+		MinMonths: between(point[2], s.MinMinMonths, s.MaxMinMonths),
+	}
+	c.Weight = append([]float64{}, point[3:3+c.NumFunds*s.slotSize()]...)
+	return c, nil
+}
+
+// between maps x in [-1, 1] to the integers from min to max.
+func between(x float64, min, max int) int {
+	return min + int(math.Round((x+1)/2*float64(max-min)))
+}
+
+// Value is what a search maximizes in the result of a candidate.
+type Value func(r *simulate.Result) float64
+
+// Values are the names of the values ParseValue accepts.
+var Values = []string{"median", "relativeCDI"}
+
+// ParseValue returns the value named median, the median performance, or
+// relativeCDI, the median performance relative to CDI.
+func ParseValue(name string) (Value, error) {
+	switch name {
+	case "median":
+		return func(r *simulate.Result) float64 { return r.Median }, nil
+	case "relativeCDI":
+		return func(r *simulate.Result) float64 { return r.MedianRelative(simulate.CDI) }, nil
+	}
+	return nil, fmt.Errorf("unknown value %v, want one of %v", name, Values)
+}
+
+// Evaluation is a candidate, its result and the value of the result.
+type Evaluation struct {
+	Candidate *Candidate
+	Result    *simulate.Result
+	Value     float64
+}
+
+// Search evaluates the points of a space in a backtest, remembering the best
+// evaluation for each number of funds.
+type Search struct {
+	space    *Space
+	backtest *simulate.Backtest
+	quantum  float64
+	value    Value
+
+	mutex sync.Mutex
+	// The caches of the weights of each structure.
+	caches map[[3]int]*simulate.Cache
+	best   map[int]*Evaluation
+}
+
+// NewSearch returns a search of space in b that maximizes value, whose caches
+// round the weights to multiples of quantum. If value is nil, the median
+// performance is maximized.
+func NewSearch(space *Space, b *simulate.Backtest, quantum float64, value Value) *Search {
+	if value == nil {
+		value = func(r *simulate.Result) float64 { return r.Median }
+	}
+	return &Search{
+		space:    space,
+		backtest: b,
+		quantum:  quantum,
+		value:    value,
+		caches:   make(map[[3]int]*simulate.Cache),
+		best:     make(map[int]*Evaluation),
+	}
+}
+
+// Objective returns the value of the candidate of point. It is an
+// optimize.Objective.
+func (s *Search) Objective(point []float64) (float64, error) {
+	e, err := s.Evaluate(point)
+	if err != nil {
+		return 0, err
+	}
+	return e.Value, nil
+}
+
+// Evaluate returns the candidate of point, with its weights rounded as in the
+// cache, and its result.
+func (s *Search) Evaluate(point []float64) (*Evaluation, error) {
+	c, err := s.space.Decode(point)
+	if err != nil {
+		return nil, err
+	}
+	cache := s.cache(c)
+	r, err := cache.Run(c.Weight)
+	if err != nil {
+		return nil, err
+	}
+	c.Weight = cache.Quantize(c.Weight)
+	e := &Evaluation{c, r, s.value(r)}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if best, ok := s.best[c.NumFunds]; !math.IsNaN(e.Value) && (!ok || e.Value > best.Value) {
+		s.best[c.NumFunds] = e
+	}
+	return e, nil
+}
+
+func (s *Search) cache(c *Candidate) *simulate.Cache {
+	key := [3]int{c.NumFunds, c.MaxMonths, c.MinMonths}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if cache, ok := s.caches[key]; ok {
+		return cache
+	}
+	b := s.backtest.Clone()
+	b.NumFunds = c.NumFunds
+	b.MinMonths = c.MinMonths
+	cache := simulate.NewCache(b, c.MaxMonths, s.quantum)
+	s.caches[key] = cache
+	return cache
+}
+
+// Best returns the best evaluation for each number of funds, by the number of
+// funds.
+func (s *Search) Best() []*Evaluation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var best []*Evaluation
+	for _, e := range s.best {
+		best = append(best, e)
+	}
+	sort.Slice(best, func(i, j int) bool {
+		return best[i].Candidate.NumFunds < best[j].Candidate.NumFunds
+	})
+	return best
+}
+
+func (s *Search) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var weightHits, weights, choiceHits, choices int
+	for _, c := range s.caches {
+		wh, w, ch, c := c.Stats()
+		weightHits += wh
+		weights += w
+		choiceHits += ch
+		choices += c
+	}
+	return fmt.Sprintf("structures: %v, weights: %v/%v hits, choices: %v/%v hits", len(s.caches), weightHits, weights, choiceHits, choices)
+}
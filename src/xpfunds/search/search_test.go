@@ -0,0 +1,115 @@
+package search
+
+import (
+	"math"
+	"testing"
+	"xpfunds"
+	"xpfunds/simulate"
+)
+
+func TestDecode(t *testing.T) {
+	s := &Space{
+		MinNumFunds: 1, MaxNumFunds: 3,
+		MinMaxMonths: 0, MaxMaxMonths: 60,
+		MinMinMonths: 10, MaxMinMonths: 10,
+		FeatureCount: 1,
+	}
+	if got, want := s.Dimension(), 3+3*3; got != want {
+		t.Errorf("dimension: got: %v, want: %v", got, want)
+	}
+	point := []float64{0, -1, 0.7, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	c, err := s.Decode(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NumFunds != 2 || c.MaxMonths != 0 || c.MinMonths != 10 {
+		t.Errorf("got: %+v, want: 2 funds, 0 maxMonths, 10 minMonths", c)
+	}
+	if want := []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}; !eqSlice(c.Weight, want) {
+		t.Errorf("weight: got: %v, want: %v", c.Weight, want)
+	}
+	point[0], point[1] = 1, 1
+	if c, _ := s.Decode(point); c.MaxMonths != 60 {
+		t.Errorf("maxMonths: got: %v, want: %v", c.MaxMonths, 60)
+	}
+	for _, n := range []int{0, 3, len(point) - 1, len(point) + 1} {
+		if _, err := s.Decode(make([]float64, n)); err == nil {
+			t.Errorf("%v coordinates: got no error", n)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	funds := []*xpfunds.Fund{
+		xpfunds.NewFund([]float64{1.1, 0.9, 1.2, 1, 1.3, 0.8}),
+		xpfunds.NewFund([]float64{1, 1.1, 0.9, 1.2, 1, 1.1}),
+		xpfunds.NewFund([]float64{1.05, 1, 1.05, 1, 1.05, 1}),
+	}
+	xpfunds.SetRatio(funds)
+	b := &simulate.Backtest{Funds: funds, MaxDuration: 6}
+	space := &Space{MinNumFunds: 1, MaxNumFunds: 2, FeatureCount: funds[0].FeatureCount()}
+	s := NewSearch(space, b, 0, nil)
+	point := make([]float64, space.Dimension())
+	point[3] = 1
+	point[3+space.slotSize()] = -1
+	for _, numFunds := range []float64{-1, 1} {
+		point[0] = numFunds
+		got, err := s.Objective(point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := space.Decode(point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := simulate.MedianPerformance(funds, 6, 0, c.NumFunds, c.Strategy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%v funds: got: %v, want: %v", c.NumFunds, got, want)
+		}
+	}
+	best := s.Best()
+	if len(best) != 2 || best[0].Candidate.NumFunds != 1 || best[1].Candidate.NumFunds != 2 {
+		t.Errorf("best: got: %v, want: one for 1 and 2 funds", best)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	r := simulate.NewResult([]*simulate.Date{
+		{Time: 3, Performance: 1.2, CDI: 1.1},
+		{Time: 2, Performance: 1.1, CDI: 1.1},
+		{Time: 1, Performance: 1.3, CDI: 1.2},
+	})
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"median", 1.2},
+		{"relativeCDI", 1.3 / 1.2},
+	}
+	for _, test := range tests {
+		value, err := ParseValue(test.name)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if got := value(r); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, test.want)
+		}
+	}
+	if _, err := ParseValue("mean"); err == nil {
+		t.Error("mean: got no error")
+	}
+}
+
+func eqSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
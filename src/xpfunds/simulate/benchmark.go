@@ -0,0 +1,176 @@
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"xpfunds"
+)
+
+// Benchmark is something the performance of a strategy is compared to.
+type Benchmark int
+
+const (
+	CDI Benchmark = iota
+
+	// IPCA plus Backtest.IPCASpread.
+	IPCA
+
+	// Investing the same in all funds.
+	EqualWeight
+
+	// Investing in the funds that will have the best return.
+	Optimum
+)
+
+// Sets the performance of the benchmarks in d.
+func (b *Backtest) compare(d *Date) error {
+	d.CDI = indexPerformance(b.CDI, d.Time, b.months(d.Time), 1)
+	d.IPCA = indexPerformance(b.IPCA, d.Time, b.months(d.Time), math.Pow(1+b.IPCASpread, 1.0/12))
+	bench, err := b.benchmark(d.Time)
+	if err != nil {
+		return err
+	}
+	d.EqualWeight = bench.EqualWeight
+	d.Optimum = bench.Optimum
+	return nil
+}
+
+// benchmarkCache remembers the benchmarks that depend on the funds. It's
+// shared by the clones of a backtest while they have the same funds.
+type benchmarkCache struct {
+	funds []*xpfunds.Fund
+	mutex sync.Mutex
+	dates map[string]*benchmarkEntry
+}
+
+type benchmarkEntry struct {
+	once sync.Once
+	date *Date
+	err  error
+}
+
+// sameFunds returns whether a and b are the same slice.
+func sameFunds(a, b []*xpfunds.Fund) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// benchmarkCache returns the cache of b, replacing it if the funds changed.
+func (b *Backtest) benchmarkCache() *benchmarkCache {
+	b.benchmarksMutex.Lock()
+	defer b.benchmarksMutex.Unlock()
+	if b.benchmarks == nil || !sameFunds(b.benchmarks.funds, b.Funds) {
+		b.benchmarks = &benchmarkCache{funds: b.Funds, dates: make(map[string]*benchmarkEntry)}
+	}
+	return b.benchmarks
+}
+
+// benchmarkKey identifies the benchmarks at time by the settings that change
+// their simulation.
+func (b *Backtest) benchmarkKey(time int) string {
+	return fmt.Sprintf("%v %v %v %v %p %#v %v %v %v %v %v", time, b.NumFunds, b.Horizon, b.Rebalance, b.Cash, b.Tax, b.LastMonth, b.Capital, b.Contributions, b.OnlyInvestable, b.Profile)
+}
+
+// The benchmarks that depend on the funds are only calculated once for each
+// time, even by concurrent calls.
+func (b *Backtest) benchmark(time int) (*Date, error) {
+	c := b.benchmarkCache()
+	key := b.benchmarkKey(time)
+	c.mutex.Lock()
+	e, ok := c.dates[key]
+	if !ok {
+		e = &benchmarkEntry{}
+		c.dates[key] = e
+	}
+	c.mutex.Unlock()
+	e.once.Do(func() {
+		equal, err := b.date(AllFunds{}, time)
+		if err != nil {
+			e.err = err
+			return
+		}
+		optimum, err := b.date(&Hindsight{b.NumFunds, b.Horizon}, time)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.date = &Date{
+			Time:        time,
+			EqualWeight: equal.Performance,
+			Optimum:     optimum.Performance,
+		}
+	})
+	return e.date, e.err
+}
+
+// The mean monthly return of index in the months after time, with each month
+// multiplied by spread.
+func indexPerformance(index *xpfunds.Fund, time, months int, spread float64) float64 {
+	total := 1.0
+	for t := time - 1; t >= time-months; t-- {
+		monthly := 1.0
+		if index != nil && index.Exists(t) {
+			monthly = index.MonthlyReturn(t)
+		}
+		total *= monthly * spread
+	}
+	return math.Pow(total, 1/float64(months))
+}
+
+// AllFunds is a strategy that chooses all the funds.
+type AllFunds struct{}
+
+func (a AllFunds) Name() string {
+	return "AllFunds"
+}
+
+func (a AllFunds) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	if len(funds) == 0 {
+		return nil, ErrNoFund
+	}
+	return funds, nil
+}
+
+// Hindsight is a strategy that chooses the NumFunds funds that will have the
+// best return in the Horizon months after the choice, or until now if Horizon
+// is 0. It's not possible to follow it, but it shows the best that could be
+// done.
+type Hindsight struct {
+	NumFunds int
+	Horizon  int
+}
+
+func (h *Hindsight) Name() string {
+	return "Hindsight"
+}
+
+func (h *Hindsight) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	if len(funds) == 0 {
+		return nil, ErrNoFund
+	}
+	future := make(map[*xpfunds.Fund]float64)
+	for _, f := range funds {
+		future[f] = 1
+		stop := 0
+		if h.Horizon > 0 {
+			stop = end - h.Horizon
+		}
+		for t := end - 1; t >= stop && f.Exists(t); t-- {
+			future[f] *= f.MonthlyReturn(t)
+		}
+	}
+	sorted := make([]*xpfunds.Fund, len(funds))
+	copy(sorted, funds)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return future[sorted[i]] > future[sorted[j]]
+	})
+	numFunds := h.NumFunds
+	if numFunds < 1 {
+		numFunds = 1
+	}
+	if numFunds > len(sorted) {
+		numFunds = len(sorted)
+	}
+	return sorted[:numFunds], nil
+}
@@ -0,0 +1,146 @@
+package simulate
+
+import (
+	"math"
+	"testing"
+	"xpfunds"
+)
+
+func TestHindsight(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1.1, 2, 1},
+		{1.5, 1, 1},
+		{1.2, 1.2, 1},
+	})
+	tests := []struct {
+		numFunds int
+		end      int
+		want     []*xpfunds.Fund
+	}{
+		{1, 1, []*xpfunds.Fund{funds[1]}},
+		{1, 2, []*xpfunds.Fund{funds[0]}},
+		{2, 2, []*xpfunds.Fund{funds[0], funds[1]}},
+		{5, 2, []*xpfunds.Fund{funds[0], funds[1], funds[2]}},
+	}
+	for _, test := range tests {
+		got, err := (&Hindsight{test.numFunds, 0}).Choose(funds, test.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%v, %v: got: %v, want: %v", test.numFunds, test.end, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%v, %v: got: %v, want: %v", test.numFunds, test.end, got, test.want)
+			}
+		}
+	}
+}
+
+func TestIndexPerformance(t *testing.T) {
+	index := xpfunds.NewFund([]float64{1.1, 1.2})
+	tests := []struct {
+		name   string
+		index  *xpfunds.Fund
+		time   int
+		spread float64
+		want   float64
+	}{
+		{"nil", nil, 2, 1, 1},
+		{"oneMonth", index, 1, 1, 1.1},
+		{"twoMonths", index, 2, 1, math.Sqrt(1.1 * 1.2)},
+		{"spread", index, 1, 1.01, 1.1 * 1.01},
+		{"beforeIndex", index, 3, 1, math.Pow(1.1*1.2, 1.0/3)},
+	}
+	for _, test := range tests {
+		if got, want := indexPerformance(test.index, test.time, test.time, test.spread), test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestRunBenchmarks(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{2, 1.1},
+			{1, 1.2},
+		}),
+		MaxDuration: 2,
+		CDI:         xpfunds.NewFund([]float64{1.25, 1.25}),
+		IPCA:        xpfunds.NewFund([]float64{1.1, 1.1}),
+		IPCASpread:  math.Pow(1.25/1.1, 12) - 1,
+	}
+	// Chooses the second fund, which is the best in the past.
+	r, err := b.Run(NewWeighted(0, weight(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(r.Dates), 1; got != want {
+		t.Fatalf("len(Dates): got: %v, want: %v", got, want)
+	}
+	tests := []struct {
+		benchmark Benchmark
+		want      float64
+	}{
+		{CDI, 0.8},
+		{IPCA, 0.8},
+		{EqualWeight, 1 / 1.5},
+		{Optimum, 0.5},
+	}
+	for _, test := range tests {
+		if got, want := r.MedianRelative(test.benchmark), test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.benchmark, got, want)
+		}
+	}
+}
+
+func TestBenchmarkCache(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{2, 1.1, 1},
+			{1, 1.2, 1},
+			{1.5, 1, 1},
+		}),
+		MaxDuration: 3,
+		NumFunds:    1,
+	}
+	s := NewWeighted(0, weight(1, 1))
+	if _, err := b.Run(s); err != nil {
+		t.Fatal(err)
+	}
+	entries := len(b.benchmarks.dates)
+	same := b.Clone()
+	if _, err := same.Run(s); err != nil {
+		t.Fatal(err)
+	}
+	if same.benchmarks != b.benchmarks || len(b.benchmarks.dates) != entries {
+		t.Errorf("same settings: got: %v entries, want: %v shared", len(b.benchmarks.dates), entries)
+	}
+	// A different number of funds has a different optimum.
+	c := b.Clone()
+	c.NumFunds = 2
+	got, err := c.Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := (&Backtest{Funds: b.Funds, MaxDuration: 3, NumFunds: 2}).Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got.Dates {
+		if got.Dates[i].Optimum != want.Dates[i].Optimum || got.Dates[i].EqualWeight != want.Dates[i].EqualWeight {
+			t.Errorf("numFunds: got: %v, want: %v", got.Dates[i], want.Dates[i])
+		}
+	}
+	// Other funds don't share the benchmarks.
+	c = b.Clone()
+	c.Funds = append([]*xpfunds.Fund{}, b.Funds[:2]...)
+	if _, err := c.Run(s); err != nil {
+		t.Fatal(err)
+	}
+	if c.benchmarks == b.benchmarks {
+		t.Errorf("funds: got: shared, want: not shared")
+	}
+}
@@ -0,0 +1,170 @@
+package simulate
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"xpfunds"
+	"xpfunds/median"
+)
+
+// Bootstrap resamples the dates of results, or the funds of backtests, to
+// estimate how much a statistic of the performance could change by chance.
+type Bootstrap struct {
+	// The number of resamples.
+	Samples int
+
+	// The number of consecutive dates resampled together, so that the
+	// correlation between close dates is kept. If 0, 1 is used.
+	Block int
+
+	// The seed of the random numbers. The same seed gives the same results.
+	Seed int64
+
+	// The statistic of the performances of the dates. If nil, the median is
+	// used.
+	Statistic func(perfs []float64) float64
+}
+
+// Interval is a confidence interval for a statistic.
+type Interval struct {
+	Low  float64
+	High float64
+
+	// The share of the resamples inside the interval, like 0.95.
+	Confidence float64
+
+	// The seed used to find the interval.
+	Seed int64
+}
+
+// ErrNoDates is returned when there are no dates to resample.
+var ErrNoDates = errors.New("no dates to resample")
+
+// Interval returns the interval of the statistic of the performances in r with
+// confidence.
+func (bs *Bootstrap) Interval(r *Result, confidence float64) (Interval, error) {
+	if err := bs.validate(); err != nil {
+		return Interval{}, err
+	}
+	perfs := r.Performances()
+	if len(perfs) == 0 {
+		return Interval{}, ErrNoDates
+	}
+	rnd := rand.New(rand.NewSource(bs.Seed))
+	stats := make([]float64, bs.Samples)
+	for i := range stats {
+		stats[i] = bs.statistic(resample(perfs, bs.sample(rnd, len(perfs))))
+	}
+	return bs.interval(stats, confidence), nil
+}
+
+// FundsInterval returns the interval of the statistic of the performances of s
+// in b with confidence, resampling the funds of b instead of the dates. The
+// ratios of the features of the resampled funds are recalculated among them.
+func (bs *Bootstrap) FundsInterval(b *Backtest, s Strategy, confidence float64) (Interval, error) {
+	if err := bs.validate(); err != nil {
+		return Interval{}, err
+	}
+	rnd := rand.New(rand.NewSource(bs.Seed))
+	stats := make([]float64, bs.Samples)
+	for i := range stats {
+		c := b.Clone()
+		c.Funds = make([]*xpfunds.Fund, len(b.Funds))
+		for j := range c.Funds {
+			c.Funds[j] = b.Funds[rnd.Intn(len(b.Funds))].Copy()
+		}
+		xpfunds.SetRatio(c.Funds)
+		r, err := c.Run(s)
+		if err != nil {
+			return Interval{}, err
+		}
+		stats[i] = bs.statistic(r.Performances())
+	}
+	return bs.interval(stats, confidence), nil
+}
+
+// Compare returns the share of the resamples in which the statistic of a is
+// not greater than the statistic of b. The same dates are used for a and b in
+// each resample, so a small value means that a is better than b. Only the dates
+// that are in both a and b are used.
+func (bs *Bootstrap) Compare(a, b *Result) (float64, error) {
+	if err := bs.validate(); err != nil {
+		return 0, err
+	}
+	perfsB := make(map[int]float64)
+	for _, d := range b.Dates {
+		perfsB[d.Time] = d.Performance
+	}
+	var pairedA, pairedB []float64
+	for _, d := range a.Dates {
+		if perf, ok := perfsB[d.Time]; ok {
+			pairedA = append(pairedA, d.Performance)
+			pairedB = append(pairedB, perf)
+		}
+	}
+	if len(pairedA) == 0 {
+		return 0, ErrNoDates
+	}
+	rnd := rand.New(rand.NewSource(bs.Seed))
+	notGreater := 0
+	for i := 0; i < bs.Samples; i++ {
+		indexes := bs.sample(rnd, len(pairedA))
+		if bs.statistic(resample(pairedA, indexes)) <= bs.statistic(resample(pairedB, indexes)) {
+			notGreater++
+		}
+	}
+	return float64(notGreater) / float64(bs.Samples), nil
+}
+
+func (bs *Bootstrap) validate() error {
+	if bs.Samples <= 0 {
+		return fmt.Errorf("%v samples, want at least 1", bs.Samples)
+	}
+	return nil
+}
+
+// Returns n indexes from 0 to n-1, in blocks of consecutive indexes.
+func (bs *Bootstrap) sample(rnd *rand.Rand, n int) []int {
+	block := bs.Block
+	if block < 1 {
+		block = 1
+	}
+	if block > n {
+		block = n
+	}
+	var indexes []int
+	for len(indexes) < n {
+		start := rnd.Intn(n - block + 1)
+		for i := start; i < start+block && len(indexes) < n; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func resample(s []float64, indexes []int) []float64 {
+	r := make([]float64, len(indexes))
+	for i, index := range indexes {
+		r[i] = s[index]
+	}
+	return r
+}
+
+func (bs *Bootstrap) statistic(perfs []float64) float64 {
+	if bs.Statistic == nil {
+		return median.Median(perfs)
+	}
+	return bs.Statistic(perfs)
+}
+
+func (bs *Bootstrap) interval(stats []float64, confidence float64) Interval {
+	sort.Float64s(stats)
+	return Interval{
+		Low:        percentile(stats, (1-confidence)/2),
+		High:       percentile(stats, (1+confidence)/2),
+		Confidence: confidence,
+		Seed:       bs.Seed,
+	}
+}
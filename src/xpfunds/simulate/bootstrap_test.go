@@ -0,0 +1,121 @@
+package simulate
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	for _, block := range []int{0, 1, 3, 10, 20} {
+		bs := &Bootstrap{Block: block}
+		indexes := bs.sample(rand.New(rand.NewSource(1)), 10)
+		if got, want := len(indexes), 10; got != want {
+			t.Errorf("%v: len: got: %v, want: %v", block, got, want)
+		}
+		size := block
+		if size > 10 {
+			size = 10
+		}
+		for i, index := range indexes {
+			if index < 0 || index >= 10 {
+				t.Errorf("%v: index out of range: %v", block, index)
+			}
+			if size > 1 && i%size != 0 && index != indexes[i-1]+1 {
+				t.Errorf("%v: not consecutive: %v", block, indexes)
+			}
+		}
+	}
+}
+
+func TestInterval(t *testing.T) {
+	r := resultWithPerformances(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	bs := &Bootstrap{Samples: 200, Block: 2, Seed: 42}
+	got, err := bs.Interval(r, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Low > r.Median || got.High < r.Median || got.Low >= got.High {
+		t.Errorf("interval %v doesn't contain the median %v", got, r.Median)
+	}
+	if got.Seed != 42 || got.Confidence != 0.9 {
+		t.Errorf("got: %v, want seed 42 and confidence 0.9", got)
+	}
+	if again, _ := bs.Interval(r, 0.9); again != got {
+		t.Errorf("not reproducible: got: %v, then: %v", got, again)
+	}
+
+	constant, _ := bs.Interval(resultWithPerformances(2, 2, 2), 0.9)
+	if !eq(constant.Low, 2) || !eq(constant.High, 2) {
+		t.Errorf("constant: got: %v, want: 2, 2", constant)
+	}
+
+	if _, err := (&Bootstrap{}).Interval(r, 0.9); err == nil {
+		t.Error("no samples: got no error")
+	}
+	if _, err := bs.Interval(resultWithPerformances(), 0.9); err != ErrNoDates {
+		t.Errorf("no dates: got: %v, want: %v", err, ErrNoDates)
+	}
+}
+
+func TestFundsInterval(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.1, 1.1, 1.1},
+			{1.2, 1.2, 1.2},
+		}),
+		MaxDuration: 3,
+	}
+	ratio := b.Funds[0].Return(0, 3)
+	bs := &Bootstrap{Samples: 10, Seed: 1}
+	if got, err := bs.FundsInterval(b, NewWeighted(0, weight(1, 1)), 0.9); err != nil {
+		t.Error(err)
+	} else if !eq(got.Low, 1.1) || !eq(got.High, 1.2) {
+		t.Errorf("got: %v, want: 1.1, 1.2", got)
+	}
+	if got := b.Funds[0].Return(0, 3); got != ratio {
+		t.Errorf("ratio of the original fund: got: %v, want: %v", got, ratio)
+	}
+	if _, err := (&Bootstrap{}).FundsInterval(b, NewWeighted(0, weight(1, 1)), 0.9); err == nil {
+		t.Error("no samples: got no error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	low := resultWithPerformances(1, 2, 3, 4, 5)
+	high := resultWithPerformances(2, 3, 4, 5, 6)
+	bs := &Bootstrap{Samples: 100, Seed: 1}
+	tests := []struct {
+		name string
+		a    *Result
+		b    *Result
+		want float64
+	}{
+		{"better", high, low, 0},
+		{"worse", low, high, 1},
+		{"same", low, low, 1},
+	}
+	for _, test := range tests {
+		got, err := bs.Compare(test.a, test.b)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if !eq(got, test.want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := (&Bootstrap{}).Compare(low, high); err == nil {
+		t.Error("no samples: got no error")
+	}
+	other := NewResult([]*Date{{Time: 100, Performance: 1}})
+	if _, err := bs.Compare(low, other); err != ErrNoDates {
+		t.Errorf("no paired dates: got: %v, want: %v", err, ErrNoDates)
+	}
+}
+
+func resultWithPerformances(perfs ...float64) *Result {
+	var dates []*Date
+	for i, perf := range perfs {
+		dates = append(dates, &Date{Time: len(perfs) - i, Performance: perf})
+	}
+	return NewResult(dates)
+}
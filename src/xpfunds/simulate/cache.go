@@ -0,0 +1,167 @@
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"xpfunds"
+)
+
+// Cache remembers the results of Weighted strategies in a backtest, so that
+// evaluating the same weights again is free. The weights are rounded before
+// being used, so close weights are considered the same. It also remembers the
+// result of each start time by the funds chosen, so different weights that
+// choose the same funds don't need to be simulated again. This is only done if
+// the backtest doesn't rebalance.
+type Cache struct {
+	backtest  *Backtest
+	maxMonths int
+	quantum   float64
+
+	mutex   sync.Mutex
+	results map[string]*cachedResult
+
+	// The number of times a result was found by the weights and the number of
+	// times it had to be calculated.
+	WeightHits   int
+	WeightMisses int
+}
+
+type cachedResult struct {
+	result *Result
+	err    error
+}
+
+// NewCache returns a cache for the Weighted strategies with maxMonths in b,
+// with the weights rounded to multiples of quantum. If quantum is 0, the
+// weights are not rounded.
+func NewCache(b *Backtest, maxMonths int, quantum float64) *Cache {
+	c := &Cache{
+		backtest:  b.Clone(),
+		maxMonths: maxMonths,
+		quantum:   quantum,
+		results:   make(map[string]*cachedResult),
+	}
+	c.backtest.choices = &choiceCache{dates: make(map[string]*Date)}
+	return c
+}
+
+// Run returns the result of the Weighted strategy with weight, after rounding
+// it.
+func (c *Cache) Run(weight []float64) (*Result, error) {
+	quantized := c.Quantize(weight)
+	key := fmt.Sprint(quantized)
+	c.mutex.Lock()
+	cached, ok := c.results[key]
+	if ok {
+		c.WeightHits++
+	} else {
+		c.WeightMisses++
+	}
+	c.mutex.Unlock()
+	if ok {
+		return cached.result, cached.err
+	}
+	r, err := c.backtest.Run(NewWeighted(c.maxMonths, quantized))
+	c.mutex.Lock()
+	c.results[key] = &cachedResult{r, err}
+	c.mutex.Unlock()
+	return r, err
+}
+
+func (c *Cache) MedianPerformance(weight []float64) (float64, error) {
+	r, err := c.Run(weight)
+	if err != nil {
+		return 0, err
+	}
+	return r.Median, nil
+}
+
+// Quantize returns weight rounded to multiples of the quantum.
+func (c *Cache) Quantize(weight []float64) []float64 {
+	quantized := make([]float64, len(weight))
+	for i, w := range weight {
+		if c.quantum == 0 {
+			quantized[i] = w
+			continue
+		}
+		quantized[i] = math.Round(w/c.quantum) * c.quantum
+	}
+	return quantized
+}
+
+// ChoiceHits and ChoiceMisses are the number of times the result of a start
+// time was found by the funds chosen and the number of times it had to be
+// simulated.
+func (c *Cache) ChoiceHits() int {
+	c.backtest.choices.mutex.Lock()
+	defer c.backtest.choices.mutex.Unlock()
+	return c.backtest.choices.hits
+}
+
+func (c *Cache) ChoiceMisses() int {
+	c.backtest.choices.mutex.Lock()
+	defer c.backtest.choices.mutex.Unlock()
+	return c.backtest.choices.misses
+}
+
+// Stats returns the number of hits and of lookups of the weights and of the
+// choices.
+func (c *Cache) Stats() (weightHits, weights, choiceHits, choices int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.backtest.choices.mutex.Lock()
+	defer c.backtest.choices.mutex.Unlock()
+	return c.WeightHits, c.WeightHits + c.WeightMisses,
+		c.backtest.choices.hits, c.backtest.choices.hits + c.backtest.choices.misses
+}
+
+func (c *Cache) String() string {
+	weightHits, weights, choiceHits, choices := c.Stats()
+	return fmt.Sprintf("weights: %v/%v hits, choices: %v/%v hits", weightHits, weights, choiceHits, choices)
+}
+
+// choiceCache remembers the dates simulated by the time and the funds chosen.
+type choiceCache struct {
+	mutex  sync.Mutex
+	dates  map[string]*Date
+	hits   int
+	misses int
+}
+
+func (cc *choiceCache) date(b *Backtest, s Strategy, time int, chosen []*xpfunds.Fund) (*Date, error) {
+	key := choiceKey(time, chosen)
+	cc.mutex.Lock()
+	d, ok := cc.dates[key]
+	if ok {
+		cc.hits++
+	} else {
+		cc.misses++
+	}
+	cc.mutex.Unlock()
+	if ok {
+		// The funds are the same, but they may have been chosen in a
+		// different order.
+		copied := *d
+		copied.Chosen = chosen
+		return &copied, nil
+	}
+	d, err := b.simulate(s, time, chosen, nil)
+	if err != nil {
+		return nil, err
+	}
+	cc.mutex.Lock()
+	cc.dates[key] = d
+	cc.mutex.Unlock()
+	return d, nil
+}
+
+func choiceKey(time int, chosen []*xpfunds.Fund) string {
+	funds := make([]string, len(chosen))
+	for i, f := range chosen {
+		funds[i] = fmt.Sprintf("%p", f)
+	}
+	sort.Strings(funds)
+	return fmt.Sprint(time, funds)
+}
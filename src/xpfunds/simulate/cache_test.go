@@ -0,0 +1,76 @@
+package simulate
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.1, 0.9, 1.2, 1, 1.3, 0.8},
+			{1, 1.1, 0.9, 1.2, 1, 1.1},
+		}),
+		MaxDuration: 6,
+	}
+	c := NewCache(b, 0, 0.1)
+	// Only the first weights choose funds not seen before.
+	var choiceMisses int
+	for _, test := range []struct {
+		name       string
+		weight     []float64
+		weightHits int
+	}{
+		{"first", weight(1, 1), 0},
+		{"same", weight(1, 1), 1},
+		{"rounded", weight(1.01, 1), 2},
+		{"scaled", weight(2, 1), 2},
+	} {
+		got, err := c.MedianPerformance(test.weight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := medianPerformance(t, b, NewWeighted(0, test.weight)); !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+		if c.WeightHits != test.weightHits {
+			t.Errorf("%v: weight hits: got: %v, want: %v", test.name, c.WeightHits, test.weightHits)
+		}
+		if choiceMisses == 0 {
+			choiceMisses = c.ChoiceMisses()
+		}
+		if got := c.ChoiceMisses(); got != choiceMisses {
+			t.Errorf("%v: choice misses: got: %v, want: %v", test.name, got, choiceMisses)
+		}
+	}
+}
+
+func TestCacheRebalance(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.1, 0.9, 1.2, 1},
+			{1, 1.1, 0.9, 1.2},
+		}),
+		MaxDuration: 4,
+		Rebalance:   1,
+	}
+	c := NewCache(b, 0, 0)
+	for _, w := range [][]float64{weight(1, 1), weight(2, 1)} {
+		got, err := c.MedianPerformance(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := medianPerformance(t, b, NewWeighted(0, w)); !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", w, got, want)
+		}
+	}
+	if got, want := c.ChoiceHits()+c.ChoiceMisses(), 0; got != want {
+		t.Errorf("choices: got: %v, want: %v", got, want)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	c := NewCache(&Backtest{}, 0, 0.25)
+	if got, want := c.Quantize([]float64{0.1, -0.4, 0.9}), []float64{0, -0.5, 1}; !eqSlice(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
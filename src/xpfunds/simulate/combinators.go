@@ -0,0 +1,227 @@
+package simulate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"xpfunds"
+)
+
+// Predicate says whether a fund can be chosen at a time.
+type Predicate struct {
+	Name    string
+	Accepts func(f *xpfunds.Fund, end int) bool
+}
+
+// MaxMinimum accepts funds whose minimum first investment is at most value.
+func MaxMinimum(value float64) Predicate {
+	return Predicate{
+		fmt.Sprintf("minimum<=%v", value),
+		func(f *xpfunds.Fund, end int) bool { return f.Minimum() <= value },
+	}
+}
+
+// MaxDays accepts funds that pay redemptions in at most days.
+func MaxDays(days int) Predicate {
+	return Predicate{
+		fmt.Sprintf("days<=%v", days),
+		func(f *xpfunds.Fund, end int) bool { return f.Days() <= days },
+	}
+}
+
+// NameContains accepts funds whose name contains s, ignoring case.
+func NameContains(s string) Predicate {
+	lower := strings.ToLower(s)
+	return Predicate{
+		fmt.Sprintf("name~%q", s),
+		func(f *xpfunds.Fund, end int) bool { return strings.Contains(strings.ToLower(f.Name()), lower) },
+	}
+}
+
+// HasTaxRegime accepts funds taxed by regime.
+func HasTaxRegime(regime xpfunds.TaxRegime) Predicate {
+	return Predicate{
+		fmt.Sprintf("regime=%v", regime),
+		func(f *xpfunds.Fund, end int) bool { return f.TaxRegime() == regime },
+	}
+}
+
+// HasMonths accepts funds with at least months of history at the time,
+// including it.
+func HasMonths(months int) Predicate {
+	return Predicate{
+		fmt.Sprintf("months>=%v", months),
+		func(f *xpfunds.Fund, end int) bool { return f.Exists(end) && f.Duration()-end >= months },
+	}
+}
+
+// And accepts funds accepted by all predicates.
+func And(predicates ...Predicate) Predicate {
+	var names []string
+	for _, p := range predicates {
+		names = append(names, p.Name)
+	}
+	return Predicate{
+		"(" + strings.Join(names, " and ") + ")",
+		func(f *xpfunds.Fund, end int) bool {
+			for _, p := range predicates {
+				if !p.Accepts(f, end) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Or accepts funds accepted by any of the predicates.
+func Or(predicates ...Predicate) Predicate {
+	var names []string
+	for _, p := range predicates {
+		names = append(names, p.Name)
+	}
+	return Predicate{
+		"(" + strings.Join(names, " or ") + ")",
+		func(f *xpfunds.Fund, end int) bool {
+			for _, p := range predicates {
+				if p.Accepts(f, end) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func Not(p Predicate) Predicate {
+	return Predicate{
+		"not " + p.Name,
+		func(f *xpfunds.Fund, end int) bool { return !p.Accepts(f, end) },
+	}
+}
+
+// Filter returns a strategy that chooses with s among the funds accepted by p.
+func Filter(p Predicate, s Strategy) Strategy {
+	return &filter{p, s}
+}
+
+type filter struct {
+	predicate Predicate
+	strategy  Strategy
+}
+
+func (f *filter) Name() string {
+	return fmt.Sprintf("Filter(%v,%v)", f.predicate.Name, f.strategy.Name())
+}
+
+func (f *filter) accepted(funds []*xpfunds.Fund, end int) []*xpfunds.Fund {
+	var accepted []*xpfunds.Fund
+	for _, fund := range funds {
+		if f.predicate.Accepts(fund, end) {
+			accepted = append(accepted, fund)
+		}
+	}
+	return accepted
+}
+
+func (f *filter) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	return f.strategy.Choose(f.accepted(funds, end), end)
+}
+
+func (f *filter) Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	return choose(f.strategy, f.accepted(funds, end), end)
+}
+
+// Sequential returns a strategy that chooses with ranker among the funds
+// chosen by narrower.
+func Sequential(narrower, ranker Strategy) Strategy {
+	return &sequential{narrower, ranker}
+}
+
+type sequential struct {
+	narrower Strategy
+	ranker   Strategy
+}
+
+func (s *sequential) Name() string {
+	return fmt.Sprintf("Sequential(%v,%v)", s.narrower.Name(), s.ranker.Name())
+}
+
+func (s *sequential) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	narrowed, err := s.narrower.Choose(funds, end)
+	if err != nil {
+		return nil, err
+	}
+	return s.ranker.Choose(narrowed, end)
+}
+
+func (s *sequential) Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	narrowed, err := s.narrower.Choose(funds, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	return choose(s.ranker, narrowed, end)
+}
+
+// Part is a strategy in a blend and the share of the money it invests.
+type Part struct {
+	Strategy Strategy
+	Share    float64
+}
+
+// Blend returns a strategy that invests the share of each part in the funds
+// it chooses. The funds chosen by more than one part get the money of all of
+// them. The parts that can't choose any fund are left out and the shares of
+// the others are scaled to add up to 1.
+func Blend(parts ...Part) Strategy {
+	return &blend{parts}
+}
+
+type blend struct {
+	parts []Part
+}
+
+func (b *blend) Name() string {
+	var names []string
+	for _, p := range b.parts {
+		names = append(names, fmt.Sprintf("%v:%v", p.Share, p.Strategy.Name()))
+	}
+	return fmt.Sprintf("Blend(%v)", strings.Join(names, ","))
+}
+
+func (b *blend) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	chosen, _, err := b.Allocate(funds, end)
+	return chosen, err
+}
+
+func (b *blend) Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	var chosen []*xpfunds.Fund
+	index := make(map[*xpfunds.Fund]int)
+	var shares []float64
+	total := 0.0
+	for _, p := range b.parts {
+		partChosen, partShares, err := choose(p.Strategy, funds, end)
+		if errors.Is(err, ErrNoFund) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		total += p.Share
+		for i, f := range partChosen {
+			if _, ok := index[f]; !ok {
+				index[f] = len(chosen)
+				chosen = append(chosen, f)
+				shares = append(shares, 0)
+			}
+			shares[index[f]] += p.Share * share(partShares, i, len(partChosen))
+		}
+	}
+	if len(chosen) == 0 || total <= 0 {
+		return nil, nil, fmt.Errorf("%v at %v with %v funds: %w", b.Name(), end, len(funds), ErrNoFund)
+	}
+	for i := range shares {
+		shares[i] /= total
+	}
+	return chosen, shares, nil
+}
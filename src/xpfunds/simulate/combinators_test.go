@@ -0,0 +1,112 @@
+package simulate
+
+import (
+	"errors"
+	"testing"
+	"xpfunds"
+)
+
+func TestPredicates(t *testing.T) {
+	f := xpfunds.NewFund([]float64{1, 1, 1})
+	f.SetName("XP Macro FIM")
+	f.SetMinimum(5000)
+	f.SetDays(31)
+	tests := []struct {
+		predicate Predicate
+		want      bool
+	}{
+		{MaxMinimum(5000), true},
+		{MaxMinimum(4999), false},
+		{MaxDays(30), false},
+		{NameContains("fim"), true},
+		{HasTaxRegime(xpfunds.LongTerm), true},
+		{HasMonths(3), true},
+		{HasMonths(4), false},
+		{And(MaxMinimum(5000), MaxDays(30)), false},
+		{Or(MaxMinimum(5000), MaxDays(30)), true},
+		{Not(MaxDays(30)), true},
+	}
+	for _, test := range tests {
+		if got := test.predicate.Accepts(f, 0); got != test.want {
+			t.Errorf("%v: got: %v, want: %v", test.predicate.Name, got, test.want)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1.3, 1.3},
+		{1.2, 1.2},
+		{1.1, 1.1},
+	})
+	funds[0].SetMinimum(10000)
+	tests := []struct {
+		strategy Strategy
+		want     []int
+		shares   []float64
+	}{
+		{Filter(MaxMinimum(5000), &Momentum{1, 2}), []int{1}, nil},
+		{Sequential(&LowVolatility{3, 2}, &Momentum{2, 2}), []int{0, 1}, nil},
+		{Blend(Part{&Momentum{2, 2}, 0.5}, Part{Filter(MaxMinimum(5000), &Momentum{1, 2}), 0.5}), []int{0, 1}, []float64{0.25, 0.75}},
+		// The filter chooses no fund, so the other part gets all the money.
+		{Blend(Part{&Momentum{1, 2}, 0.25}, Part{Filter(MaxMinimum(-1), &Momentum{1, 2}), 0.75}), []int{0}, []float64{1}},
+	}
+	for _, test := range tests {
+		chosen, shares, err := choose(test.strategy, funds, 0)
+		if err != nil {
+			t.Errorf("%v: %v", test.strategy.Name(), err)
+			continue
+		}
+		var want []*xpfunds.Fund
+		for _, i := range test.want {
+			want = append(want, funds[i])
+		}
+		if !eqFunds(chosen, want) {
+			t.Errorf("%v: got: %v, want: %v", test.strategy.Name(), chosen, want)
+		}
+		if !eqSlice(shares, test.shares) {
+			t.Errorf("%v: shares: got: %v, want: %v", test.strategy.Name(), shares, test.shares)
+		}
+	}
+	// The best by momentum of the two funds Random picks.
+	picks, err := (&Random{2, 1}).Choose(funds, 0)
+	if err != nil || len(picks) != 2 {
+		t.Fatalf("random: got: %v, %v, want: 2 funds", picks, err)
+	}
+	want, err := (&Momentum{1, 2}).Choose(picks, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Sequential(&Random{2, 1}, &Momentum{1, 2}).Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eqFunds(got, want) || (got[0] != picks[0] && got[0] != picks[1]) {
+		t.Errorf("sequential random: got: %v, want: %v of %v", got, want, picks)
+	}
+	if _, err := Filter(MaxMinimum(-1), &Momentum{1, 2}).Choose(funds, 0); !errors.Is(err, ErrNoFund) {
+		t.Errorf("filter: got: %v, want: %v", err, ErrNoFund)
+	}
+}
+
+func TestPerformanceBlend(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.2, 1.3},
+			{0.9, 1.1},
+		}),
+		MaxDuration: 2,
+		End:         1,
+		Start:       1,
+	}
+	s := Blend(Part{&Momentum{1, 1}, 0.75}, Part{&Momentum{2, 1}, 0.25})
+	// The first part chooses the fund 0 and the second both.
+	d, err := b.date(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0.875*1.2 + 0.125*0.9
+	if d.Chosen[0] != b.Funds[0] || !eq(d.Performance, want) {
+		t.Errorf("got: %v, want: %v", d.Performance, want)
+	}
+}
@@ -0,0 +1,171 @@
+package simulate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"xpfunds"
+)
+
+// WeightedConfig describes a Weighted strategy with named parameters, so that
+// it can be saved and read.
+type WeightedConfig struct {
+	// The most months a slot can read or require.
+	MaxMonths int
+	// The fewest months of the funds of the backtest the strategy was chosen
+	// in. The strategy doesn't use it, but it's needed to simulate it again.
+	MinMonths int
+	// How each fund is chosen, in order.
+	Slots []*Slot
+}
+
+// Slot is how one of the funds of a Weighted strategy is chosen: the fund
+// with the largest sum of its features, relative to the best fund, times
+// their weights.
+type Slot struct {
+	// The weight of each feature, by its name in xpfunds.FeatureNames, from -1
+	// to 1. Missing features weigh 0.
+	Features map[string]float64
+	// The months whose features are read. If 0, all months are read.
+	MonthsToRead int
+	// Funds with fewer months than read plus these are ignored.
+	IgnoreWithoutMonths int
+}
+
+// ReadWeightedConfig reads a config in JSON and validates it.
+func ReadWeightedConfig(file string) (*WeightedConfig, error) {
+	text, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &WeightedConfig{}
+	if err := json.Unmarshal(text, c); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return c, nil
+}
+
+// Write writes c as JSON to file.
+func (c *WeightedConfig) Write(file string) error {
+	text, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(text, '\n'), 0644)
+}
+
+func (c *WeightedConfig) Validate() error {
+	if c.MaxMonths < 0 {
+		return fmt.Errorf("negative MaxMonths %v", c.MaxMonths)
+	}
+	if c.MinMonths < 0 {
+		return fmt.Errorf("negative MinMonths %v", c.MinMonths)
+	}
+	if len(c.Slots) == 0 {
+		return errors.New("no slots")
+	}
+	for i, s := range c.Slots {
+		if s == nil {
+			return fmt.Errorf("slot %v: empty", i+1)
+		}
+		for name, w := range s.Features {
+			if featureIndex(name) < 0 {
+				return fmt.Errorf("slot %v: unknown feature %q, want one of %v", i+1, name, xpfunds.FeatureNames)
+			}
+			if w < -1 || w > 1 || math.IsNaN(w) {
+				return fmt.Errorf("slot %v: weight of %v is %v, want from -1 to 1", i+1, name, w)
+			}
+		}
+		if s.MonthsToRead < 0 || s.MonthsToRead > c.MaxMonths {
+			return fmt.Errorf("slot %v: MonthsToRead is %v, want from 0 to MaxMonths %v", i+1, s.MonthsToRead, c.MaxMonths)
+		}
+		if s.IgnoreWithoutMonths < 0 || s.IgnoreWithoutMonths > c.MaxMonths {
+			return fmt.Errorf("slot %v: IgnoreWithoutMonths is %v, want from 0 to MaxMonths %v", i+1, s.IgnoreWithoutMonths, c.MaxMonths)
+		}
+	}
+	return nil
+}
+
+func featureIndex(name string) int {
+	for i, n := range xpfunds.FeatureNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Weighted returns the strategy described by c.
+func (c *WeightedConfig) Weighted() (*Weighted, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var weight []float64
+	for _, s := range c.Slots {
+		features := make([]float64, len(xpfunds.FeatureNames))
+		for name, w := range s.Features {
+			features[featureIndex(name)] = w
+		}
+		weight = append(weight, features...)
+		weight = append(weight, monthsWeight(s.MonthsToRead, c.MaxMonths), monthsWeight(s.IgnoreWithoutMonths, c.MaxMonths))
+	}
+	return NewWeighted(c.MaxMonths, weight), nil
+}
+
+// Name describes each slot by its features that weigh something and its
+// months.
+func (c *WeightedConfig) Name() string {
+	var slots []string
+	for _, s := range c.Slots {
+		var fields []string
+		for _, name := range xpfunds.FeatureNames {
+			if w := s.Features[name]; w != 0 {
+				fields = append(fields, fmt.Sprintf("%v=%v", name, w))
+			}
+		}
+		fields = append(fields, fmt.Sprintf("read=%v", s.MonthsToRead), fmt.Sprintf("ignore=%v", s.IgnoreWithoutMonths))
+		slots = append(slots, strings.Join(fields, " "))
+	}
+	return fmt.Sprintf("Weighted(maxMonths=%v; %v)", c.MaxMonths, strings.Join(slots, "; "))
+}
+
+// Config returns the config of w, with the months it reads after rounding.
+func (w *Weighted) Config() *WeightedConfig {
+	c := &WeightedConfig{MaxMonths: w.maxMonths}
+	featureCount := len(xpfunds.FeatureNames)
+	slotSize := featureCount + w.FeatureCount()
+	for i := 0; i+slotSize <= len(w.weight); i += slotSize {
+		s := &Slot{
+			Features:            make(map[string]float64),
+			MonthsToRead:        w.months(w.weight[i+featureCount]),
+			IgnoreWithoutMonths: w.months(w.weight[i+featureCount+1]),
+		}
+		for j, name := range xpfunds.FeatureNames {
+			if w.weight[i+j] != 0 {
+				s.Features[name] = w.weight[i+j]
+			}
+		}
+		c.Slots = append(c.Slots, s)
+	}
+	return c
+}
+
+// months returns the months of a weight of monthsToRead or
+// ignoreWithoutMonths.
+func (w *Weighted) months(weight float64) int {
+	return int(math.Round((weight + 1) / 2 * float64(w.maxMonths)))
+}
+
+// monthsWeight returns the weight that is turned into months.
+func monthsWeight(months, maxMonths int) float64 {
+	if maxMonths == 0 {
+		return -1
+	}
+	return float64(months)*2/float64(maxMonths) - 1
+}
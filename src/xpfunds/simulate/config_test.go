@@ -0,0 +1,74 @@
+package simulate
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWeightedConfig(t *testing.T) {
+	c := &WeightedConfig{
+		MaxMonths: 12,
+		Slots: []*Slot{{
+			Features:     map[string]float64{"return": 1, "stdDev": -0.5},
+			MonthsToRead: 6,
+		}, {
+			Features:            map[string]float64{"median": 0.25},
+			IgnoreWithoutMonths: 12,
+		}},
+	}
+	w, err := c.Weighted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, -0.5, 0, 0, 0, 0, 0, -1, 0, 0, 0, 0.25, 0, 0, -1, 1}
+	if !eqSlice(w.weight, want) {
+		t.Errorf("weight: got: %v, want: %v", w.weight, want)
+	}
+	if got := w.Config(); !reflect.DeepEqual(got, c) {
+		t.Errorf("config: got: %v, want: %v", got.Name(), c.Name())
+	}
+	wantName := "Weighted(maxMonths=12; return=1 stdDev=-0.5 read=6 ignore=0; median=0.25 read=0 ignore=12)"
+	if got := w.Name(); got != wantName {
+		t.Errorf("name: got: %v, want: %v", got, wantName)
+	}
+
+	c.MinMonths = 3
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := c.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadWeightedConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, c) {
+		t.Errorf("read: got: %v, want: %v", read.Name(), c.Name())
+	}
+}
+
+func TestWeightedConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *WeightedConfig
+		want   string
+	}{
+		{"noSlots", &WeightedConfig{MaxMonths: 1}, "no slots"},
+		{"minMonths", &WeightedConfig{MinMonths: -1, Slots: []*Slot{{}}}, "negative MinMonths -1"},
+		{"unknownFeature", &WeightedConfig{Slots: []*Slot{{Features: map[string]float64{"sharpe": 1}}}}, `slot 1: unknown feature "sharpe"`},
+		{"weight", &WeightedConfig{Slots: []*Slot{{}, {Features: map[string]float64{"return": 2}}}}, "slot 2: weight of return is 2"},
+		{"monthsToRead", &WeightedConfig{MaxMonths: 3, Slots: []*Slot{{MonthsToRead: 4}}}, "slot 1: MonthsToRead is 4"},
+		{"ignore", &WeightedConfig{MaxMonths: 3, Slots: []*Slot{{IgnoreWithoutMonths: -1}}}, "slot 1: IgnoreWithoutMonths is -1"},
+		{"negativeMaxMonths", &WeightedConfig{MaxMonths: -1, Slots: []*Slot{{}}}, "negative MaxMonths"},
+	}
+	for _, test := range tests {
+		err := test.config.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, err, test.want)
+		}
+	}
+	if err := (&WeightedConfig{Slots: []*Slot{{}}}).Validate(); err != nil {
+		t.Errorf("valid: got: %v, want: nil", err)
+	}
+}
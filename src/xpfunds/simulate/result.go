@@ -0,0 +1,172 @@
+package simulate
+
+import (
+	"math"
+	"sort"
+	"xpfunds"
+	"xpfunds/median"
+)
+
+// Date is the outcome of investing in a strategy from a start time until now.
+type Date struct {
+	Time int
+
+	// The mean monthly return.
+	Performance float64
+
+	// The mean monthly return of the benchmarks in the same period.
+	CDI         float64
+	IPCA        float64
+	EqualWeight float64
+	Optimum     float64
+
+	// The funds chosen at Time.
+	Chosen []*xpfunds.Fund
+
+	// The value of the investment in the end of each month, starting with 1
+	// and not counting contributions.
+	Curve []float64
+}
+
+// Relative returns the performance divided by the performance of benchmark, or
+// NaN if benchmark is unknown.
+func (d *Date) Relative(benchmark Benchmark) float64 {
+	switch benchmark {
+	case CDI:
+		return d.Performance / d.CDI
+	case IPCA:
+		return d.Performance / d.IPCA
+	case EqualWeight:
+		return d.Performance / d.EqualWeight
+	case Optimum:
+		return d.Performance / d.Optimum
+	}
+	return math.NaN()
+}
+
+// Result is the outcome of investing in a strategy from each possible start
+// time.
+type Result struct {
+	// From the oldest to the most recent start time.
+	Dates []*Date
+
+	// Statistics of the performance of the dates.
+	Mean   float64
+	Median float64
+	Worst  float64
+
+	// The share of the dates in which the performance was better than CDI.
+	BeatCDI float64
+
+	// Statistics of the curve of the oldest date, which covers the whole
+	// period. The return is a factor (1.1 for 10%) and the drawdown is the
+	// share of the value lost (0.1 for 10%).
+	AnnualReturn     float64
+	AnnualVolatility float64
+	MaxDrawdown      float64
+
+	// The performances of the dates, sorted.
+	sorted []float64
+}
+
+func NewResult(dates []*Date) *Result {
+	r := &Result{
+		Dates:  dates,
+		Median: -1,
+	}
+	if len(dates) == 0 {
+		return r
+	}
+	beat := 0
+	for _, d := range dates {
+		r.sorted = append(r.sorted, d.Performance)
+		r.Mean += d.Performance
+		if d.Performance > d.CDI {
+			beat++
+		}
+	}
+	sort.Float64s(r.sorted)
+	r.Mean /= float64(len(dates))
+	r.Median = median.MedianFromSorted(r.sorted)
+	r.Worst = r.sorted[0]
+	r.BeatCDI = float64(beat) / float64(len(dates))
+	r.setCurveStatistics(dates[0].Curve)
+	return r
+}
+
+func (r *Result) setCurveStatistics(curve []float64) {
+	if len(curve) == 0 {
+		return
+	}
+	r.AnnualReturn = math.Pow(curve[len(curve)-1], 12/float64(len(curve)))
+	var monthly []float64
+	previous := 1.0
+	peak := 1.0
+	for _, v := range curve {
+		monthly = append(monthly, v/previous)
+		previous = v
+		if v > peak {
+			peak = v
+		}
+		if drawdown := 1 - v/peak; drawdown > r.MaxDrawdown {
+			r.MaxDrawdown = drawdown
+		}
+	}
+	r.AnnualVolatility = stdDev(monthly) * math.Sqrt(12)
+}
+
+// Relative returns the performance of each date divided by the performance of
+// benchmark, in the same order as Dates.
+func (r *Result) Relative(benchmark Benchmark) []float64 {
+	rel := make([]float64, len(r.Dates))
+	for i, d := range r.Dates {
+		rel[i] = d.Relative(benchmark)
+	}
+	return rel
+}
+
+func (r *Result) MedianRelative(benchmark Benchmark) float64 {
+	return median.Median(r.Relative(benchmark))
+}
+
+// Performances returns the performance of each date, in the same order as
+// Dates.
+func (r *Result) Performances() []float64 {
+	perfs := make([]float64, len(r.Dates))
+	for i, d := range r.Dates {
+		perfs[i] = d.Performance
+	}
+	return perfs
+}
+
+// Percentile returns the performance below which there are p (0-1) of the
+// dates, interpolating between dates.
+func (r *Result) Percentile(p float64) float64 {
+	return percentile(r.sorted, p)
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return -1
+	}
+	pos := p * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+func stdDev(s []float64) float64 {
+	total := 0.0
+	for _, v := range s {
+		total += v
+	}
+	avg := total / float64(len(s))
+	sumDiffs := 0.0
+	for _, v := range s {
+		diff := v - avg
+		sumDiffs += diff * diff
+	}
+	return math.Sqrt(sumDiffs / float64(len(s)))
+}
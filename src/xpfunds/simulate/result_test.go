@@ -0,0 +1,155 @@
+package simulate
+
+import (
+	"math"
+	"testing"
+	"xpfunds"
+)
+
+func TestNewResult(t *testing.T) {
+	r := NewResult([]*Date{{
+		Time:        3,
+		Performance: 1.1,
+		CDI:         1.2,
+		Curve:       []float64{1.2, 0.6, 0.9},
+	}, {
+		Time:        2,
+		Performance: 1.3,
+		CDI:         1.2,
+	}, {
+		Time:        1,
+		Performance: 1.2,
+		CDI:         1.1,
+	}})
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"Mean", r.Mean, 1.2},
+		{"Median", r.Median, 1.2},
+		{"Worst", r.Worst, 1.1},
+		{"BeatCDI", r.BeatCDI, 2.0 / 3.0},
+		{"AnnualReturn", r.AnnualReturn, math.Pow(0.9, 4)},
+		{"AnnualVolatility", r.AnnualVolatility, stdDev([]float64{1.2, 0.5, 1.5}) * math.Sqrt(12)},
+		{"MaxDrawdown", r.MaxDrawdown, 0.5},
+		{"Percentile0", r.Percentile(0), 1.1},
+		{"Percentile25", r.Percentile(0.25), 1.15},
+		{"Percentile100", r.Percentile(1), 1.3},
+	}
+	for _, test := range tests {
+		if !eq(test.got, test.want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, test.got, test.want)
+		}
+	}
+	if got, want := r.Performances(), []float64{1.1, 1.3, 1.2}; !eqSlice(got, want) {
+		t.Errorf("Performances: got: %v, want: %v", got, want)
+	}
+}
+
+func TestRelative(t *testing.T) {
+	d := &Date{Performance: 1.2, CDI: 1.1, IPCA: 1.05, EqualWeight: 1, Optimum: 1.5}
+	for _, test := range []struct {
+		benchmark Benchmark
+		want      float64
+	}{
+		{CDI, 1.2 / 1.1},
+		{IPCA, 1.2 / 1.05},
+		{EqualWeight, 1.2},
+		{Optimum, 0.8},
+	} {
+		if got := d.Relative(test.benchmark); !eq(got, test.want) {
+			t.Errorf("%v: got: %v, want: %v", test.benchmark, got, test.want)
+		}
+	}
+	if got := d.Relative(Optimum + 1); !math.IsNaN(got) {
+		t.Errorf("unknown: got: %v, want: NaN", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1, 1, 1.1},
+		{1.5, 2, 1.2},
+	})
+	b := &Backtest{
+		Funds:       funds,
+		MaxDuration: 3,
+		NumFunds:    1,
+		CDI:         xpfunds.NewFund([]float64{1.6, 1.6, 1.6}),
+	}
+	r, err := b.Run(NewWeighted(0, weight(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(r.Dates), 2; got != want {
+		t.Fatalf("len(Dates): got: %v, want: %v", got, want)
+	}
+	for i, time := range []int{2, 1} {
+		d := r.Dates[i]
+		if got, want := d.Time, time; got != want {
+			t.Errorf("Time: got: %v, want: %v", got, want)
+		}
+		if len(d.Chosen) != 1 || d.Chosen[0] != funds[1] {
+			t.Errorf("%v: Chosen: got: %v, want: %v", time, d.Chosen, funds[1:])
+		}
+		if got, want := len(d.Curve), time; got != want {
+			t.Errorf("%v: len(Curve): got: %v, want: %v", time, got, want)
+		}
+		if got, want := d.CDI, 1.6; !eq(got, want) {
+			t.Errorf("%v: CDI: got: %v, want: %v", time, got, want)
+		}
+	}
+	if got, want := r.BeatCDI, 0.5; !eq(got, want) {
+		t.Errorf("BeatCDI: got: %v, want: %v", got, want)
+	}
+	if got, want := r.Dates[0].Curve, []float64{2, 3}; !eqSlice(got, want) {
+		t.Errorf("Curve: got: %v, want: %v", got, want)
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1.1, 0.9, 1.2, 1, 1.3, 0.8, 1, 1.1, 1.2, 0.9},
+		{1, 1.1, 0.9, 1.2, 1, 1.1, 1.2, 0.9, 1, 1.1},
+		{1.05, 1, 1.1, 0.95, 1.2, 1, 1.1, 1, 0.9, 1.2},
+	})
+	var want []float64
+	for _, workers := range []int{1, 2, 4, 0, -1} {
+		b := &Backtest{
+			Funds:       funds,
+			MaxDuration: 10,
+			NumFunds:    1,
+			Rebalance:   2,
+			Workers:     workers,
+		}
+		r, err := b.Run(NewWeighted(0, weight(1, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var times []int
+		for _, d := range r.Dates {
+			times = append(times, d.Time)
+		}
+		if wantTimes := []int{9, 8, 7, 6, 5, 4, 3, 2, 1}; !eqInts(times, wantTimes) {
+			t.Errorf("%v workers: times: got: %v, want: %v", workers, times, wantTimes)
+		}
+		if want == nil {
+			want = r.Performances()
+		} else if got := r.Performances(); !eqSlice(got, want) {
+			t.Errorf("%v workers: got: %v, want: %v", workers, got, want)
+		}
+	}
+}
+
+func eqSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !eq(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,50 +1,544 @@
 package simulate
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"runtime"
+	"sync"
+	"time"
 	"xpfunds"
-	"xpfunds/median"
 )
 
-func MedianPerformance(funds []*xpfunds.Fund, maxDuration, minMonths, maxNumFunds int, s Strategy) float64 {
-	var perfs []float64
+// The number of days in a month, used to convert the days of a redemption to
+// months.
+const daysInMonth = 30
 
-	for time := maxDuration - 1; time >= 1; time-- {
-		var active []*xpfunds.Fund
+// Backtest holds everything needed to simulate a strategy apart from the
+// strategy itself.
+type Backtest struct {
+	Funds       []*xpfunds.Fund
+	MaxDuration int
+	MinMonths   int
+	NumFunds    int
+
+	// The choices are made from End, inclusive, to Start, exclusive. If Start
+	// is 0, MaxDuration is used. Each choice is evaluated in the following
+	// Horizon months. If Horizon is 0, it's evaluated until now.
+	Start   int
+	End     int
+	Horizon int
+
+	// The number of months between two choices of the strategy. 0 means that
+	// the funds chosen in the beginning are held until the end.
+	Rebalance int
+
+	// Where the money stays while a redemption is not finished, usually CDI.
+	// If nil, the money doesn't earn anything in this period.
+	Cash *xpfunds.Fund
+
+	// The taxes charged on the investments. If nil, the performance is before
+	// taxes. Otherwise, everything is redeemed in the end and the performance
+	// is after taxes.
+	Tax Tax
+
+	// The month of the year of time 0, used for taxes charged in specific
+	// months.
+	LastMonth time.Month
+
+	// The money invested in the beginning. A fund can only be bought if its
+	// minimum investment is not greater than the money divided by NumFunds.
+	// If 0, the minimum investment is ignored.
+	Capital float64
+
+	// The money invested in the beginning of each month after the first, in
+	// the funds chosen last.
+	Contributions []float64
+
+	// Used to compare the performance of the strategy. If nil, they are
+	// considered to have no return. The performance is also compared to
+	// IPCA plus IPCASpread, which is yearly (0.05 for 5%).
+	CDI        *xpfunds.Fund
+	IPCA       *xpfunds.Fund
+	IPCASpread float64
+
+	// Whether only the funds an investor with Profile could buy are chosen.
+	// Funds already held can still be kept.
+	OnlyInvestable bool
+	Profile        xpfunds.Profile
+
+	// The number of goroutines simulating the strategy from different times at
+	// the same time. If 0 or less, the number of CPUs is used.
+	Workers int
+
+	// The dates already simulated by the funds chosen, if they are remembered.
+	choices *choiceCache
+
+	// The benchmarks that depend on the funds, shared with the clones.
+	benchmarks      *benchmarkCache
+	benchmarksMutex sync.Mutex
+}
+
+func MedianPerformance(funds []*xpfunds.Fund, maxDuration, minMonths, maxNumFunds int, s Strategy) (float64, error) {
+	b := &Backtest{
+		Funds:       funds,
+		MaxDuration: maxDuration,
+		MinMonths:   minMonths,
+		NumFunds:    maxNumFunds,
+	}
+	return b.MedianPerformance(s)
+}
+
+func (b *Backtest) MedianPerformance(s Strategy) (float64, error) {
+	r, err := b.Run(s)
+	if err != nil {
+		return 0, err
+	}
+	return r.Median, nil
+}
+
+// Run simulates s starting from each time in which there are enough funds. It
+// returns an error if s can't choose funds at some time. The times are
+// simulated in parallel, so s must support concurrent calls to Choose.
+func (b *Backtest) Run(s Strategy) (*Result, error) {
+	var times []int
+	for time := b.start() - 1; time >= b.end(); time-- {
 		withMinMonths := 0
-		for _, f := range funds {
-			if f.Duration() >= time+1 {
-				active = append(active, f)
-			}
-			if f.Duration() >= time+minMonths+1 {
+		for _, f := range b.active(time) {
+			if f.Duration() >= time+b.MinMonths+1 {
 				withMinMonths++
 			}
 		}
-		if len(active) < maxNumFunds+1 || withMinMonths == 0 {
+		if len(b.available(time, &portfolio{})) < b.NumFunds+1 || withMinMonths == 0 {
+			continue
+		}
+		times = append(times, time)
+	}
+	dates := make([]*Date, len(times))
+	errs := make([]error, len(times))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				dates[index], errs[index] = b.evaluate(s, times[index])
+			}
+		}()
+	}
+	for index := range times {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewResult(dates), nil
+}
+
+func (b *Backtest) workers() int {
+	if b.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return b.Workers
+}
+
+// Simulates s from time and compares it to the benchmarks.
+func (b *Backtest) evaluate(s Strategy, time int) (*Date, error) {
+	d, err := b.date(s, time)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.compare(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (b *Backtest) start() int {
+	if b.Start == 0 {
+		return b.MaxDuration
+	}
+	return b.Start
+}
+
+func (b *Backtest) end() int {
+	end := b.End
+	if b.Horizon > end {
+		end = b.Horizon
+	}
+	if end < 1 {
+		return 1
+	}
+	return end
+}
+
+// The number of months in which a choice made at time is evaluated.
+func (b *Backtest) months(time int) int {
+	if b.Horizon == 0 {
+		return time
+	}
+	return b.Horizon
+}
+
+// The funds that can be chosen at time, including the ones that were delisted
+// later.
+func (b *Backtest) active(time int) []*xpfunds.Fund {
+	var active []*xpfunds.Fund
+	for _, f := range b.Funds {
+		if f.Exists(time) {
+			active = append(active, f)
+		}
+	}
+	return active
+}
+
+// The funds that can be chosen at time, considering the money in p and the
+// profile of the investor. The funds already in p can always be chosen.
+func (b *Backtest) available(time int, p *portfolio) []*xpfunds.Fund {
+	money := b.Capital
+	if len(p.positions) > 0 || len(p.redemptions) > 0 {
+		money = p.value()
+	}
+	if b.NumFunds > 0 {
+		money /= float64(b.NumFunds)
+	}
+	var available []*xpfunds.Fund
+	for _, f := range b.active(time) {
+		if p.holds(f) {
+			available = append(available, f)
+			continue
+		}
+		if b.Capital != 0 && f.Minimum() > money {
+			continue
+		}
+		if b.OnlyInvestable && !f.Investable(b.Profile, time) {
+			continue
+		}
+		available = append(available, f)
+	}
+	return available
+}
+
+// Simulates investing in the strategy from time until the end of the horizon.
+func (b *Backtest) date(s Strategy, time int) (*Date, error) {
+	chosen, shares, err := choose(s, b.available(time, &portfolio{}), time)
+	if err != nil {
+		return nil, err
+	}
+	// The funds chosen don't determine the date if they have shares.
+	if shares == nil && b.choices != nil && b.Rebalance == 0 {
+		return b.choices.date(b, s, time, chosen)
+	}
+	return b.simulate(s, time, chosen, shares)
+}
+
+// Simulates investing in the strategy from time, when chosen was chosen with
+// shares.
+func (b *Backtest) simulate(s Strategy, time int, chosen []*xpfunds.Fund, shares []float64) (*Date, error) {
+	p := &portfolio{
+		cash:      b.Cash,
+		tax:       b.Tax,
+		lastMonth: b.LastMonth,
+	}
+	capital := b.Capital
+	if capital == 0 {
+		capital = 1
+	}
+	d := &Date{
+		Time:   time,
+		Chosen: chosen,
+	}
+	p.buy(d.Chosen, shares, capital)
+	growth := 1.0
+	for t := time; t > time-b.months(time); t-- {
+		month := time - t
+		if month > 0 && b.Rebalance > 0 && month%b.Rebalance == 0 {
+			chosen, shares, err := choose(s, b.available(t, p), t)
+			if err != nil {
+				return nil, err
+			}
+			p.rebalance(chosen, shares)
+		}
+		if month > 0 && month <= len(b.Contributions) {
+			p.buy(p.chosen, p.shares, b.Contributions[month-1])
+		}
+		before := p.value()
+		p.month(t - 1)
+		growth *= p.value() / before
+		d.Curve = append(d.Curve, growth)
+	}
+	if p.tax != nil {
+		before := p.value()
+		p.rebalance(nil, nil)
+		growth *= p.value() / before
+		d.Curve[len(d.Curve)-1] = growth
+	}
+	d.Performance = math.Pow(growth, 1/float64(b.months(time)))
+	return d, nil
+}
+
+// portfolio is the money invested while simulating a strategy.
+type portfolio struct {
+	cash      *xpfunds.Fund
+	tax       Tax
+	lastMonth time.Month
+	chosen    []*xpfunds.Fund
+	// The share of the money of each fund chosen, or nil if they're equal.
+	shares      []float64
+	positions   []*position
+	redemptions []*redemption
+}
+
+// position is an investment in a fund. Investments in the same fund made at
+// different times are different positions, because they are taxed
+// separately.
+type position struct {
+	fund  *xpfunds.Fund
+	value float64
+
+	// The value invested.
+	cost float64
+
+	// The value after the last periodic tax charged, or the value invested if
+	// none was. A loss since then is offset by the following gains.
+	base float64
+
+	// The total periodic tax already paid.
+	paid float64
+
+	// For how long it was held.
+	days int
+}
+
+// redemption is the money from a sold fund that is not yet available. When it
+// is, it will be split between funds. If there are no funds, the money waits
+// in cash for the next choice.
+type redemption struct {
+	value float64
+	days  int
+	funds []*xpfunds.Fund
+	// The share of the money of each fund, or nil if they're equal.
+	shares []float64
+}
+
+// Buys funds with value, split by shares, or equally if shares is nil.
+func (p *portfolio) buy(funds []*xpfunds.Fund, shares []float64, value float64) {
+	p.chosen = funds
+	p.shares = shares
+	for i, f := range funds {
+		p.add(f, value*share(shares, i, len(funds)))
+	}
+}
+
+// share returns the share of the i-th of n funds.
+func share(shares []float64, i, n int) float64 {
+	if shares == nil {
+		return 1 / float64(n)
+	}
+	return shares[i]
+}
+
+func (p *portfolio) add(f *xpfunds.Fund, value float64) {
+	p.positions = append(p.positions, &position{
+		fund:  f,
+		value: value,
+		cost:  value,
+		base:  value,
+	})
+}
+
+// Sells the funds that were not chosen and uses the money to buy the chosen
+// funds that are not yet held. If all chosen funds are held, the money is
+// split between all of them. The money is split by the shares of the funds
+// bought, or equally if shares is nil.
+func (p *portfolio) rebalance(chosen []*xpfunds.Fund, shares []float64) {
+	isChosen := make(map[*xpfunds.Fund]bool)
+	for _, f := range chosen {
+		isChosen[f] = true
+	}
+	p.chosen = chosen
+	p.shares = shares
+	var bought []*xpfunds.Fund
+	var boughtShares []float64
+	total := 0.0
+	for i, f := range chosen {
+		if !p.holds(f) {
+			bought = append(bought, f)
+			if shares != nil {
+				boughtShares = append(boughtShares, shares[i])
+				total += shares[i]
+			}
+		}
+	}
+	for i := range boughtShares {
+		boughtShares[i] /= total
+	}
+	if len(bought) == 0 {
+		bought = chosen
+		boughtShares = shares
+	}
+	for _, r := range p.redemptions {
+		if len(r.funds) == 0 {
+			r.funds = bought
+			r.shares = boughtShares
+		}
+	}
+	var kept []*position
+	for _, pos := range p.positions {
+		if isChosen[pos.fund] {
+			kept = append(kept, pos)
+			continue
+		}
+		p.redeem(pos, pos.fund.Days(), bought, boughtShares)
+	}
+	p.positions = kept
+}
+
+func (p *portfolio) redeem(pos *position, days int, funds []*xpfunds.Fund, shares []float64) {
+	value := pos.value
+	if p.tax != nil {
+		value -= p.tax.Redemption(pos.fund, pos.cost, pos.value, pos.paid, pos.days)
+	}
+	p.redemptions = append(p.redemptions, &redemption{value, days, funds, shares})
+}
+
+// Moves the money in funds that don't exist at time to cash.
+func (p *portfolio) delisted(time int) {
+	var kept []*position
+	for _, pos := range p.positions {
+		if pos.fund.Exists(time) {
+			kept = append(kept, pos)
+			continue
+		}
+		p.redeem(pos, 0, nil, nil)
+	}
+	p.positions = kept
+}
+
+func (p *portfolio) holds(f *xpfunds.Fund) bool {
+	for _, pos := range p.positions {
+		if pos.fund == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies the returns of the month at time.
+func (p *portfolio) month(time int) {
+	p.delisted(time)
+	for _, pos := range p.positions {
+		pos.value *= pos.fund.MonthlyReturn(time)
+		pos.days += daysInMonth
+	}
+	var pending []*redemption
+	for _, r := range p.redemptions {
+		if r.days >= daysInMonth {
+			r.value *= p.cashReturn(time, 1)
+			r.days -= daysInMonth
+			pending = append(pending, r)
+			continue
+		}
+		if len(r.funds) == 0 {
+			r.value *= p.cashReturn(time, 1)
+			r.days = 0
+			pending = append(pending, r)
+			continue
+		}
+		waiting := float64(r.days) / daysInMonth
+		value := r.value * p.cashReturn(time, waiting)
+		for i, f := range r.funds {
+			value := value * share(r.shares, i, len(r.funds))
+			if !f.Exists(time) {
+				pending = append(pending, &redemption{value * p.cashReturn(time, 1-waiting), 0, nil, nil})
+				continue
+			}
+			p.add(f, value)
+			pos := p.positions[len(p.positions)-1]
+			pos.value *= math.Pow(f.MonthlyReturn(time), 1-waiting)
+			pos.days = daysInMonth - r.days
+		}
+	}
+	p.redemptions = pending
+	p.periodicTax(time)
+}
+
+func (p *portfolio) periodicTax(time int) {
+	if p.tax == nil || p.lastMonth == 0 {
+		return
+	}
+	month := calendarMonth(p.lastMonth, time)
+	for _, pos := range p.positions {
+		tax := p.tax.Periodic(pos.fund, pos.base, pos.value, month)
+		if tax == 0 {
 			continue
 		}
-		perfs = append(perfs, performance(active, s, time))
+		pos.value -= tax
+		pos.paid += tax
+		pos.base = pos.value
+	}
+}
+
+// The month of the year of t, given the month of the year of time 0.
+func calendarMonth(last time.Month, t int) time.Month {
+	return (last+11-time.Month(t%12))%12 + 1
+}
+
+// The return of the cash in the fraction of the month at time.
+func (p *portfolio) cashReturn(time int, fraction float64) float64 {
+	if p.cash == nil || time >= p.cash.Duration() {
+		return 1
 	}
-	return median.Median(perfs)
+	return math.Pow(p.cash.MonthlyReturn(time), fraction)
 }
 
-func performance(funds []*xpfunds.Fund, s Strategy, time int) float64 {
-	chosenFunds := s.Choose(funds, time)
+func (p *portfolio) value() float64 {
 	total := 0.0
-	for _, f := range chosenFunds {
-		total += f.Return(0, time)
+	for _, pos := range p.positions {
+		total += pos.value
+	}
+	for _, r := range p.redemptions {
+		total += r.value
 	}
-	return total / float64(len(chosenFunds))
+	return total
 }
 
 type Strategy interface {
 	Name() string
 
-	Choose(funds []*xpfunds.Fund, end int) []*xpfunds.Fund
+	// Choose returns the funds to invest in at end, among funds. It returns an
+	// error if no fund can be chosen. It may be called concurrently.
+	Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error)
+}
+
+// Allocator is a strategy that doesn't split the money equally between the
+// funds it chooses.
+type Allocator interface {
+	Strategy
+
+	// Allocate returns the funds to invest in at end, like Choose, and the
+	// share of the money of each, which add up to 1.
+	Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error)
 }
 
+// choose returns the funds chosen by s and their shares, which are nil if
+// they're equal.
+func choose(s Strategy, funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	if a, ok := s.(Allocator); ok {
+		return a.Allocate(funds, end)
+	}
+	chosen, err := s.Choose(funds, end)
+	return chosen, nil, err
+}
+
+// ErrNoFund is returned by strategies that can't choose any fund.
+var ErrNoFund = errors.New("no fund can be chosen")
+
 type Weighted struct {
 	maxMonths int
 	weight    []float64
@@ -57,24 +551,31 @@ func NewWeighted(maxMonths int, weight []float64) *Weighted {
 	}
 }
 
+// Name describes the config of w, if the weights are of whole slots.
 func (w *Weighted) Name() string {
-	return fmt.Sprintf("Weighted(%v,%v)", w.maxMonths, w.weight)
+	if len(w.weight)%(len(xpfunds.FeatureNames)+w.FeatureCount()) != 0 {
+		return fmt.Sprintf("Weighted(%v,%v)", w.maxMonths, w.weight)
+	}
+	return w.Config().Name()
 }
 
-func (w *Weighted) Choose(funds []*xpfunds.Fund, end int) []*xpfunds.Fund {
+// Choose returns the funds in the order they were chosen.
+func (w *Weighted) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	if len(funds) == 0 {
+		return nil, fmt.Errorf("%v at %v: %w", w.Name(), end, ErrNoFund)
+	}
 	fundFeatureCount := funds[0].FeatureCount()
 	featureCount := fundFeatureCount + w.FeatureCount()
 	numFunds := len(w.weight) / featureCount
 	chosen := make(map[*xpfunds.Fund]bool)
+	var ret []*xpfunds.Fund
 	for i := 0; i < numFunds; i++ {
 		var bestFund *xpfunds.Fund
 		bestValue := -999999.99
 		for _, f := range funds {
-			monthsToReadWeight := w.weight[i*featureCount+fundFeatureCount]
-			monthsToRead := int(math.Round((monthsToReadWeight + 1) / 2 * float64(w.maxMonths)))
-			ignoreWithoutMonthsWeight := w.weight[i*featureCount+fundFeatureCount+1]
-			ignoreWithoutMonths := int(math.Round((ignoreWithoutMonthsWeight + 1) / 2 * float64(w.maxMonths)))
-			if chosen[f] || f.Duration()-end < monthsToRead+ignoreWithoutMonths {
+			monthsToRead := w.months(w.weight[i*featureCount+fundFeatureCount])
+			ignoreWithoutMonths := w.months(w.weight[i*featureCount+fundFeatureCount+1])
+			if chosen[f] || !f.Exists(end) || f.Duration()-end < monthsToRead+ignoreWithoutMonths {
 				continue
 			}
 			start := end + monthsToRead
@@ -91,20 +592,12 @@ func (w *Weighted) Choose(funds []*xpfunds.Fund, end int) []*xpfunds.Fund {
 			break
 		}
 		chosen[bestFund] = true
+		ret = append(ret, bestFund)
 	}
 	if len(chosen) == 0 {
-		for _, f := range funds {
-			log.Print(f.Duration() - end)
-		}
-		log.Fatal("len(funds)=", len(funds), " w=", w.Name())
-	}
-	ret := make([]*xpfunds.Fund, len(chosen))
-	i := 0
-	for f := range chosen {
-		ret[i] = f
-		i++
+		return nil, fmt.Errorf("%v at %v with %v funds: %w", w.Name(), end, len(funds), ErrNoFund)
 	}
-	return ret
+	return ret, nil
 }
 
 func (w *Weighted) FeatureCount() int {
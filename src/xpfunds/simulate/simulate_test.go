@@ -1,6 +1,7 @@
 package simulate
 
 import (
+	"errors"
 	"math"
 	"testing"
 	"xpfunds"
@@ -21,7 +22,7 @@ func TestPerformance(t *testing.T) {
 		},
 		1,
 		1,
-		1,
+		2,
 	}, {
 		"reverse",
 		[][]float64{
@@ -30,7 +31,7 @@ func TestPerformance(t *testing.T) {
 		},
 		1,
 		1,
-		0.5,
+		1,
 	}, {
 		"twoFunds",
 		[][]float64{
@@ -40,7 +41,7 @@ func TestPerformance(t *testing.T) {
 		},
 		2,
 		1,
-		0.5,
+		1.5,
 	}, {
 		"clearBestNegative",
 		[][]float64{
@@ -49,7 +50,7 @@ func TestPerformance(t *testing.T) {
 		},
 		1,
 		-1,
-		0.5,
+		1,
 	}, {
 		"reverseNegative",
 		[][]float64{
@@ -58,7 +59,7 @@ func TestPerformance(t *testing.T) {
 		},
 		1,
 		-1,
-		1,
+		2,
 	}, {
 		"twoFundsNegative",
 		[][]float64{
@@ -68,154 +69,361 @@ func TestPerformance(t *testing.T) {
 		},
 		2,
 		-1,
-		0.6666666666666666,
+		2,
 	}}
 	for _, test := range tests {
-		var funds []*xpfunds.Fund
-		for _, monthly := range test.monthlys {
-			funds = append(funds, xpfunds.NewFund(monthly))
-		}
-		xpfunds.SetRatio(funds)
-		if perf, ok := performance(funds, test.numFunds, NewWeighted(0, []float64{test.weight, 1, 1}), 1); !ok {
-			t.Errorf("%v: Not ok", test.name)
-		} else if got, want := perf, test.want; !eq(got, want) {
+		b := &Backtest{Funds: newFunds(test.monthlys)}
+		if got, want := performance(t, b, NewWeighted(0, weight(test.weight, test.numFunds)), 1), test.want; !eq(got, want) {
 			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
 		}
 	}
 }
 
-func TestMedianPerformance(t *testing.T) {
+func TestPerformanceRedemption(t *testing.T) {
 	tests := []struct {
-		name     string
-		monthlys [][]float64
-		numFunds int
-		weight   float64
-		want     float64
+		name      string
+		days      int
+		cash      []float64
+		rebalance int
+		want      float64
 	}{{
-		"clearBest",
-		[][]float64{
-			{1, 1.1},
-			{2, 1.2},
-		},
-		1,
-		1,
-		1,
+		"noRebalance",
+		30,
+		nil,
+		0,
+		0.5,
 	}, {
-		"reverse",
-		[][]float64{
-			{2, 1.1},
-			{1, 1.2},
-		},
-		1,
+		"instantaneous",
+		0,
+		nil,
 		1,
-		0.5,
+		0.75,
 	}, {
-		"twoFunds",
-		[][]float64{
-			{3, 1.1},
-			{1, 1.2},
-			{2, 1.3},
-		},
-		2,
+		"halfMonth",
+		15,
+		nil,
 		1,
-		0.5,
+		0.5 * math.Sqrt(1.5),
 	}, {
-		"clearBestNegative",
-		[][]float64{
-			{1, 1.1},
-			{2, 1.2},
-		},
+		"wholeMonth",
+		30,
+		nil,
 		1,
-		-1,
 		0.5,
 	}, {
-		"reverseNegative",
-		[][]float64{
-			{2, 1.1},
-			{1, 1.2},
-		},
+		"wholeMonthCash",
+		30,
+		[]float64{1.2, 1.2, 1.2},
 		1,
-		-1,
+		0.6,
+	}, {
+		"moreThanAMonthCash",
+		45,
+		[]float64{1.2, 1.2, 1.2},
 		1,
+		0.6,
+	}}
+	for _, test := range tests {
+		// The first fund is the best until time 1, when the second fund
+		// becomes the best.
+		funds := newFunds([][]float64{
+			{1, 0.5, 2},
+			{1.5, 1.5, 1},
+		})
+		funds[0].SetDays(test.days)
+		b := &Backtest{
+			Funds:     funds,
+			Rebalance: test.rebalance,
+		}
+		if test.cash != nil {
+			b.Cash = xpfunds.NewFund(test.cash)
+		}
+		got := math.Pow(performance(t, b, NewWeighted(0, weight(1, 1)), 2), 2)
+		if want := test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestPerformanceCapital(t *testing.T) {
+	tests := []struct {
+		name          string
+		capital       float64
+		contributions []float64
+		want          float64
+	}{{
+		"unconstrained",
+		0,
+		nil,
+		math.Sqrt(2.4),
 	}, {
-		"twoFundsNegative",
-		[][]float64{
-			{3, 1.1},
-			{1, 1.2},
-			{2, 1.3},
-		},
+		"small",
+		10000,
+		nil,
+		1.1,
+	}, {
+		"large",
+		1000000,
+		nil,
+		math.Sqrt(2.4),
+	}, {
+		"contributions",
+		10000,
+		[]float64{10000, 10000},
+		1.1,
+	}}
+	for _, test := range tests {
+		// The first fund is the best, but has a high minimum investment.
+		funds := newFunds([][]float64{
+			{2, 1.2, 1.2},
+			{1.1, 1.1, 1.1},
+		})
+		funds[0].SetMinimum(500000)
+		funds[1].SetMinimum(1000)
+		b := &Backtest{
+			Funds:         funds,
+			NumFunds:      1,
+			Capital:       test.capital,
+			Contributions: test.contributions,
+		}
+		got := performance(t, b, NewWeighted(0, weight(1, 1)), 2)
+		if want := test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestPerformanceInvestable(t *testing.T) {
+	tests := []struct {
+		name           string
+		onlyInvestable bool
+		profile        xpfunds.Profile
+		want           float64
+	}{{
+		"allFunds",
+		false,
+		xpfunds.Retail,
 		2,
-		-1,
-		0.6666666666666666,
 	}, {
-		"3clearBest",
+		"retail",
+		true,
+		xpfunds.Retail,
+		1.1,
+	}, {
+		"qualified",
+		true,
+		xpfunds.Qualified,
+		2,
+	}}
+	for _, test := range tests {
+		// The best fund is only for qualified investors and the second best
+		// is closed.
+		funds := newFunds([][]float64{
+			{2, 1.3},
+			{1.5, 1.2},
+			{1.1, 1.1},
+		})
+		funds[0].SetProfile(xpfunds.Qualified)
+		funds[1].SetClosed(true)
+		b := &Backtest{
+			Funds:          funds,
+			OnlyInvestable: test.onlyInvestable,
+			Profile:        test.profile,
+		}
+		got := performance(t, b, NewWeighted(0, weight(1, 1)), 1)
+		if want := test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestPerformanceDelisted(t *testing.T) {
+	tests := []struct {
+		name      string
+		cash      []float64
+		rebalance int
+		want      float64
+	}{{
+		"withoutCash",
+		nil,
+		0,
+		0.5,
+	}, {
+		"cash",
+		[]float64{1.2, 1.2, 1.2, 1.2},
+		0,
+		0.5 * 1.2 * 1.2,
+	}, {
+		"rebalance",
+		nil,
+		2,
+		0.5 * 1.1,
+	}}
+	for _, test := range tests {
+		// The first fund is the best at time 3, but falls and is delisted
+		// after time 2.
+		delisted := xpfunds.NewFund([]float64{0.5, 2})
+		delisted.SetEnd(2)
+		funds := []*xpfunds.Fund{
+			delisted,
+			xpfunds.NewFund([]float64{1.1, 1.1, 1.1, 1.1}),
+		}
+		xpfunds.SetRatio(funds)
+		b := &Backtest{
+			Funds:     funds,
+			Rebalance: test.rebalance,
+		}
+		if test.cash != nil {
+			b.Cash = xpfunds.NewFund(test.cash)
+		}
+		got := math.Pow(performance(t, b, NewWeighted(0, weight(1, 1)), 3), 3)
+		if want := test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestMedianPerformance(t *testing.T) {
+	tests := []struct {
+		name     string
+		monthlys [][]float64
+		numFunds int
+		weight   float64
+		want     float64
+	}{{
+		"clearBest",
 		[][]float64{
 			{1, 1, 1.1},
 			{1.5, 2, 1.2},
 		},
 		1,
 		1,
-		1,
+		(math.Sqrt(3) + 1.5) / 2,
 	}, {
-		"3reverse",
+		"reverse",
 		[][]float64{
 			{1.5, 2, 1.1},
 			{1, 1, 1.2},
 		},
 		1,
 		1,
-		0.6666666666666663,
+		1.25,
 	}, {
-		"3twoFunds",
-		[][]float64{
-			{2.5, 3, 1.1},
-			{1, 1, 1.2},
-			{2, 2, 1.3},
-		},
-		2,
-		1,
-		0.6166666666666667,
-	}, {
-		"3clearBestNegative",
+		"clearBestNegative",
 		[][]float64{
 			{1, 1, 1.1},
 			{1.5, 2, 1.2},
 		},
 		1,
 		-1,
-		0.5,
-	}, {
-		"3reverseNegative",
-		[][]float64{
-			{1.5, 2, 1.1},
-			{1, 1, 1.2},
-		},
 		1,
-		-1,
-		0.8333333333333333,
 	}, {
-		"3twoFundsNegative",
+		"twoFunds",
 		[][]float64{
 			{2.5, 3, 1.1},
 			{1, 1, 1.2},
 			{2, 2, 1.3},
 		},
 		2,
-		-1,
-		0.5833333333333333,
+		1,
+		(math.Sqrt(2.5) + 2.25) / 2,
 	}}
 	for _, test := range tests {
-		var funds []*xpfunds.Fund
-		for _, monthly := range test.monthlys {
-			funds = append(funds, xpfunds.NewFund(monthly))
-		}
-		xpfunds.SetRatio(funds)
-		if got, want := MedianPerformance(funds, 3, test.numFunds, NewWeighted(0, []float64{test.weight, 1, 1})), test.want; !eq(got, want) {
+		got, err := MedianPerformance(newFunds(test.monthlys), 3, 0, test.numFunds, NewWeighted(0, weight(test.weight, test.numFunds)))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if want := test.want; !eq(got, want) {
 			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
 		}
 	}
 }
 
+func TestChooseOrder(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1, 1.1},
+		{1, 1.3},
+		{1, 1.2},
+	})
+	for i := 0; i < 10; i++ {
+		got, err := NewWeighted(0, weight(1, 3)).Choose(funds, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || got[0] != funds[1] || got[1] != funds[2] || got[2] != funds[0] {
+			t.Fatalf("got: %v, want: %v", got, []*xpfunds.Fund{funds[1], funds[2], funds[0]})
+		}
+	}
+}
+
+func TestChooseDelisted(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1, 1.1},
+		{1.3, 1.3},
+	})
+	funds[1].SetEnd(1)
+	got, err := NewWeighted(0, weight(1, 2)).Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != funds[0] {
+		t.Errorf("got: %v, want: %v", got, funds[:1])
+	}
+}
+
+func TestChooseNoFund(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1, 1.1},
+		{1, 1.3},
+	})
+	// Requires more months than the funds have.
+	w := NewWeighted(4, []float64{1, 0, 0, 0, 0, 0, 1, -1})
+	if _, err := w.Choose(funds, 1); !errors.Is(err, ErrNoFund) {
+		t.Errorf("Choose: got: %v, want: %v", err, ErrNoFund)
+	}
+	if _, err := w.Choose(nil, 1); !errors.Is(err, ErrNoFund) {
+		t.Errorf("Choose without funds: got: %v, want: %v", err, ErrNoFund)
+	}
+	b := &Backtest{
+		Funds:       funds,
+		MaxDuration: 2,
+	}
+	if _, err := b.Run(w); !errors.Is(err, ErrNoFund) {
+		t.Errorf("Run: got: %v, want: %v", err, ErrNoFund)
+	}
+}
+
+// The performance of s in b from time, failing t if there's an error.
+func performance(t *testing.T, b *Backtest, s Strategy, time int) float64 {
+	d, err := b.date(s, time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Performance
+}
+
+func newFunds(monthlys [][]float64) []*xpfunds.Fund {
+	var funds []*xpfunds.Fund
+	for _, monthly := range monthlys {
+		funds = append(funds, xpfunds.NewFund(monthly))
+	}
+	xpfunds.SetRatio(funds)
+	return funds
+}
+
+// A weight for Weighted that only considers the return of the whole history
+// of the funds.
+func weight(ret float64, numFunds int) []float64 {
+	var w []float64
+	for i := 0; i < numFunds; i++ {
+		w = append(w, ret)
+		for j := 1; j < xpfunds.NewFund([]float64{1}).FeatureCount(); j++ {
+			w = append(w, 0)
+		}
+		w = append(w, -1, -1)
+	}
+	return w
+}
+
 func eq(a, b float64) bool {
 	return math.Abs(a-b) < 0.000001
 }
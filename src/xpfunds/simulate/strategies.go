@@ -0,0 +1,268 @@
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"xpfunds"
+)
+
+// The strategies in this file are simple rules, to compare tuned strategies
+// against. They only read the months before the choice: the month at end and
+// the Months before it. If Months is 0, they read all the months of each
+// fund. Funds without all the months are not chosen.
+
+// trailing returns the monthly returns of f in the months months before end,
+// inclusive, and whether f existed in all of them.
+func trailing(f *xpfunds.Fund, end, months int) ([]float64, bool) {
+	if months == 0 {
+		months = f.Duration() - end
+	}
+	if months <= 0 || !f.Exists(end) || !f.Exists(end+months-1) {
+		return nil, false
+	}
+	returns := make([]float64, months)
+	for i := range returns {
+		returns[i] = f.MonthlyReturn(end + i)
+	}
+	return returns, true
+}
+
+// best returns the numFunds funds with the largest scores, at least one.
+// Funds without a score are not chosen.
+func best(name string, funds []*xpfunds.Fund, end, numFunds int, score func(f *xpfunds.Fund) (float64, bool)) ([]*xpfunds.Fund, error) {
+	scores := make(map[*xpfunds.Fund]float64)
+	var scored []*xpfunds.Fund
+	for _, f := range funds {
+		if s, ok := score(f); ok && !math.IsNaN(s) {
+			scores[f] = s
+			scored = append(scored, f)
+		}
+	}
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("%v at %v with %v funds: %w", name, end, len(funds), ErrNoFund)
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scores[scored[i]] > scores[scored[j]]
+	})
+	if numFunds < 1 {
+		numFunds = 1
+	}
+	if numFunds > len(scored) {
+		numFunds = len(scored)
+	}
+	return scored[:numFunds], nil
+}
+
+func product(returns []float64) float64 {
+	p := 1.0
+	for _, r := range returns {
+		p *= r
+	}
+	return p
+}
+
+func mean(returns []float64) float64 {
+	sum := 0.0
+	for _, r := range returns {
+		sum += r
+	}
+	return sum / float64(len(returns))
+}
+
+// Momentum chooses the funds with the largest return in the last months.
+type Momentum struct {
+	NumFunds int
+	Months   int
+}
+
+func (m *Momentum) Name() string {
+	return fmt.Sprintf("Momentum(%v,%v)", m.NumFunds, m.Months)
+}
+
+func (m *Momentum) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	return best(m.Name(), funds, end, m.NumFunds, func(f *xpfunds.Fund) (float64, bool) {
+		returns, ok := trailing(f, end, m.Months)
+		if !ok {
+			return 0, false
+		}
+		return math.Pow(product(returns), 1/float64(len(returns))), true
+	})
+}
+
+// LowVolatility chooses the funds with the smallest standard deviation of the
+// monthly returns in the last months.
+type LowVolatility struct {
+	NumFunds int
+	Months   int
+}
+
+func (l *LowVolatility) Name() string {
+	return fmt.Sprintf("LowVolatility(%v,%v)", l.NumFunds, l.Months)
+}
+
+func (l *LowVolatility) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	return best(l.Name(), funds, end, l.NumFunds, func(f *xpfunds.Fund) (float64, bool) {
+		returns, ok := trailing(f, end, l.Months)
+		if !ok || len(returns) < 2 {
+			return 0, false
+		}
+		return -stdDev(returns), true
+	})
+}
+
+// Sharpe chooses the funds with the largest mean monthly return above CDI per
+// standard deviation in the last months. Without CDI, the return is compared
+// to 0.
+type Sharpe struct {
+	NumFunds int
+	Months   int
+	CDI      *xpfunds.Fund
+}
+
+func (s *Sharpe) Name() string {
+	return fmt.Sprintf("Sharpe(%v,%v)", s.NumFunds, s.Months)
+}
+
+func (s *Sharpe) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	return best(s.Name(), funds, end, s.NumFunds, func(f *xpfunds.Fund) (float64, bool) {
+		returns, ok := trailing(f, end, s.Months)
+		if !ok || len(returns) < 2 {
+			return 0, false
+		}
+		excess := make([]float64, len(returns))
+		for i, r := range returns {
+			risk := 1.0
+			if s.CDI != nil && s.CDI.Exists(end+i) {
+				risk = s.CDI.MonthlyReturn(end + i)
+			}
+			excess[i] = r - risk
+		}
+		sd := stdDev(excess)
+		if sd == 0 {
+			return 0, false
+		}
+		return mean(excess) / sd, true
+	})
+}
+
+// CDIPlus chooses the funds whose return in the last months beat CDI plus
+// Spread, which is yearly (0.02 for 2%), those with the largest return first.
+// If NumFunds is 0, it chooses all of them. If Months is 0, it is 24.
+type CDIPlus struct {
+	NumFunds int
+	Months   int
+	CDI      *xpfunds.Fund
+	Spread   float64
+}
+
+func (c *CDIPlus) Name() string {
+	return fmt.Sprintf("CDIPlus(%v,%v,%v)", c.NumFunds, c.Months, c.Spread)
+}
+
+func (c *CDIPlus) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	months := c.Months
+	if months == 0 {
+		months = 24
+	}
+	numFunds := c.NumFunds
+	if numFunds == 0 {
+		numFunds = len(funds)
+	}
+	// indexPerformance reads the months before its time.
+	target := indexPerformance(c.CDI, end+months, months, math.Pow(1+c.Spread, 1.0/12))
+	return best(c.Name(), funds, end, numFunds, func(f *xpfunds.Fund) (float64, bool) {
+		returns, ok := trailing(f, end, months)
+		if !ok {
+			return 0, false
+		}
+		perf := math.Pow(product(returns), 1/float64(len(returns)))
+		return perf, perf > target
+	})
+}
+
+// RiskParity chooses the funds of Strategy and splits the money between them
+// in inverse proportion to the standard deviation of their monthly returns in
+// the last months.
+type RiskParity struct {
+	Strategy Strategy
+	Months   int
+}
+
+func (r *RiskParity) Name() string {
+	return fmt.Sprintf("RiskParity(%v,%v)", r.Strategy.Name(), r.Months)
+}
+
+func (r *RiskParity) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	return r.Strategy.Choose(funds, end)
+}
+
+// Allocate splits the money equally between the funds without the months to
+// measure their volatility or without volatility, as if they had the average
+// inverse volatility of the others.
+func (r *RiskParity) Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	chosen, err := r.Strategy.Choose(funds, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares := make([]float64, len(chosen))
+	total, measured := 0.0, 0
+	for i, f := range chosen {
+		returns, ok := trailing(f, end, r.Months)
+		if !ok || len(returns) < 2 {
+			continue
+		}
+		if sd := stdDev(returns); sd > 0 {
+			shares[i] = 1 / sd
+			total += shares[i]
+			measured++
+		}
+	}
+	average := 1.0
+	if measured > 0 {
+		average = total / float64(measured)
+	}
+	total = 0
+	for i := range shares {
+		if shares[i] == 0 {
+			shares[i] = average
+		}
+		total += shares[i]
+	}
+	for i := range shares {
+		shares[i] /= total
+	}
+	return chosen, shares, nil
+}
+
+// Random chooses random funds. The same Seed and end always choose the same
+// funds.
+type Random struct {
+	NumFunds int
+	Seed     int64
+}
+
+func (r *Random) Name() string {
+	return fmt.Sprintf("Random(%v,%v)", r.NumFunds, r.Seed)
+}
+
+func (r *Random) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	if len(funds) == 0 {
+		return nil, fmt.Errorf("%v at %v: %w", r.Name(), end, ErrNoFund)
+	}
+	// A source per choice keeps it the same when choices are concurrent.
+	rnd := rand.New(rand.NewSource(r.Seed*1000003 + int64(end)))
+	numFunds := r.NumFunds
+	if numFunds < 1 {
+		numFunds = 1
+	}
+	if numFunds > len(funds) {
+		numFunds = len(funds)
+	}
+	var chosen []*xpfunds.Fund
+	for _, i := range rnd.Perm(len(funds))[:numFunds] {
+		chosen = append(chosen, funds[i])
+	}
+	return chosen, nil
+}
@@ -0,0 +1,137 @@
+package simulate
+
+import (
+	"errors"
+	"testing"
+	"xpfunds"
+)
+
+func TestStrategies(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1.1, 1.0, 0.5},
+		{1.0, 1.0, 2.0},
+		{1.02, 1.01, 1.03},
+		{1.1, 0.9, 1.05},
+	})
+	cdi := xpfunds.NewFund([]float64{1.01, 1.01, 1.01})
+	tests := []struct {
+		strategy Strategy
+		end      int
+		want     []int
+	}{
+		{&Momentum{1, 2}, 0, []int{0}},
+		{&Momentum{2, 3}, 0, []int{1, 2}},
+		{&Momentum{1, 0}, 1, []int{1}},
+		{&LowVolatility{1, 2}, 0, []int{1}},
+		{&LowVolatility{2, 3}, 0, []int{2, 3}},
+		{&Sharpe{1, 3, nil}, 0, []int{2}},
+		{&Sharpe{1, 3, cdi}, 0, []int{2}},
+		{&CDIPlus{0, 3, cdi, 0}, 0, []int{1, 2, 3}},
+		{&CDIPlus{1, 3, cdi, 0}, 0, []int{1}},
+		{&RiskParity{&Momentum{1, 2}, 2}, 0, []int{0}},
+	}
+	for _, test := range tests {
+		got, err := test.strategy.Choose(funds, test.end)
+		if err != nil {
+			t.Errorf("%v: %v", test.strategy.Name(), err)
+			continue
+		}
+		var want []*xpfunds.Fund
+		for _, i := range test.want {
+			want = append(want, funds[i])
+		}
+		if !eqFunds(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.strategy.Name(), got, want)
+		}
+	}
+}
+
+func TestStrategiesNoFund(t *testing.T) {
+	funds := newFunds([][]float64{{1.0, 1.0}})
+	cdi := xpfunds.NewFund([]float64{1.01, 1.01})
+	for _, s := range []Strategy{
+		&Momentum{1, 3},
+		&LowVolatility{1, 1},
+		&Sharpe{1, 2, nil},
+		&CDIPlus{0, 2, cdi, 0},
+		&Random{1, 1},
+	} {
+		chosenFunds := funds
+		if _, ok := s.(*Random); ok {
+			chosenFunds = nil
+		}
+		if _, err := s.Choose(chosenFunds, 0); !errors.Is(err, ErrNoFund) {
+			t.Errorf("%v: got: %v, want: %v", s.Name(), err, ErrNoFund)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	funds := newFunds([][]float64{{1}, {1}, {1}, {1}, {1}, {1}, {1}, {1}})
+	s := &Random{3, 7}
+	first, err := s.Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 {
+		t.Errorf("got: %v funds, want: 3", len(first))
+	}
+	again, err := s.Choose(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eqFunds(first, again) {
+		t.Errorf("got: %v, then %v", first, again)
+	}
+}
+
+func TestRiskParity(t *testing.T) {
+	funds := newFunds([][]float64{
+		{1.1, 0.9},
+		{1.2, 0.8},
+		{1},
+	})
+	r := &RiskParity{AllFunds{}, 2}
+	chosen, got, err := r.Allocate(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eqFunds(chosen, funds) {
+		t.Errorf("chosen: got: %v, want: %v", chosen, funds)
+	}
+	// The inverse volatilities are 10 and 5, and the fund without the months
+	// gets their average.
+	want := []float64{10.0 / 22.5, 5 / 22.5, 7.5 / 22.5}
+	if !eqSlice(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestPerformanceAllocator(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.2, 1.1, 0.9, 1.1},
+			{0.9, 1.2, 0.8, 1.2},
+		}),
+		MaxDuration: 4,
+		End:         1,
+		Start:       1,
+	}
+	// At 1, the inverse volatilities of the months 1 and 2 are 10 and 5.
+	got := performance(t, b, &RiskParity{AllFunds{}, 2}, 1)
+	if want := 1.2*2/3 + 0.9/3; !eq(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func eqFunds(a, b []*xpfunds.Fund) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
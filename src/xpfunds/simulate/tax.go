@@ -0,0 +1,67 @@
+package simulate
+
+import (
+	"math"
+	"time"
+	"xpfunds"
+)
+
+// Tax calculates the taxes paid by an investment in a fund.
+type Tax interface {
+	// Redemption is the tax paid when an investment of cost, made days ago, is
+	// redeemed with value. paid is what was already charged by Periodic.
+	Redemption(f *xpfunds.Fund, cost, value, paid float64, days int) float64
+
+	// Periodic is the tax charged at the end of month over the gain since base,
+	// the value after the last charge, while the investment is held.
+	Periodic(f *xpfunds.Fund, base, value float64, month time.Month) float64
+}
+
+// BrazilianTax charges IOF in the first 30 days, the income tax by the tax
+// regime of the fund and come-cotas in May and November.
+type BrazilianTax struct{}
+
+// The IOF rate for each day of the first 30 days.
+var iof = []float64{
+	1, 0.96, 0.93, 0.90, 0.86, 0.83, 0.80, 0.76, 0.73, 0.70,
+	0.66, 0.63, 0.60, 0.56, 0.53, 0.50, 0.46, 0.43, 0.40, 0.36,
+	0.33, 0.30, 0.26, 0.23, 0.20, 0.16, 0.13, 0.10, 0.06, 0.03,
+}
+
+func (t BrazilianTax) Redemption(f *xpfunds.Fund, cost, value, paid float64, days int) float64 {
+	gain := value + paid - cost
+	if gain <= 0 {
+		return 0
+	}
+	iofTax := 0.0
+	if f.TaxRegime() != xpfunds.Equity && days < len(iof) {
+		iofTax = gain * iof[days]
+	}
+	return iofTax + math.Max(incomeTax(f.TaxRegime(), days)*(gain-iofTax)-paid, 0)
+}
+
+func (t BrazilianTax) Periodic(f *xpfunds.Fund, base, value float64, month time.Month) float64 {
+	if f.TaxRegime() == xpfunds.Equity || (month != time.May && month != time.November) || value <= base {
+		return 0
+	}
+	rate := 0.15
+	if f.TaxRegime() == xpfunds.ShortTerm {
+		rate = 0.2
+	}
+	return (value - base) * rate
+}
+
+// The income tax rate for an investment held for days.
+func incomeTax(regime xpfunds.TaxRegime, days int) float64 {
+	switch {
+	case regime == xpfunds.Equity:
+		return 0.15
+	case days <= 180:
+		return 0.225
+	case days <= 360 || regime == xpfunds.ShortTerm:
+		return 0.2
+	case days <= 720:
+		return 0.175
+	}
+	return 0.15
+}
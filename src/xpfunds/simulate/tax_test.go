@@ -0,0 +1,198 @@
+package simulate
+
+import (
+	"math"
+	"testing"
+	"time"
+	"xpfunds"
+)
+
+func TestRedemption(t *testing.T) {
+	tests := []struct {
+		name   string
+		regime xpfunds.TaxRegime
+		value  float64
+		paid   float64
+		days   int
+		want   float64
+	}{{
+		"loss",
+		xpfunds.LongTerm,
+		0.9,
+		0,
+		60,
+		0,
+	}, {
+		"iof",
+		xpfunds.LongTerm,
+		1.1,
+		0,
+		15,
+		0.05 + 0.05*0.225,
+	}, {
+		"equityWithoutIof",
+		xpfunds.Equity,
+		1.1,
+		0,
+		15,
+		0.015,
+	}, {
+		"shortest",
+		xpfunds.LongTerm,
+		1.1,
+		0,
+		180,
+		0.0225,
+	}, {
+		"oneYear",
+		xpfunds.LongTerm,
+		1.1,
+		0,
+		360,
+		0.02,
+	}, {
+		"twoYears",
+		xpfunds.LongTerm,
+		1.1,
+		0,
+		720,
+		0.0175,
+	}, {
+		"longest",
+		xpfunds.LongTerm,
+		1.1,
+		0,
+		721,
+		0.015,
+	}, {
+		"shortTerm",
+		xpfunds.ShortTerm,
+		1.1,
+		0,
+		721,
+		0.02,
+	}, {
+		"comeCotasPaid",
+		xpfunds.LongTerm,
+		1.09,
+		0.01,
+		721,
+		0.005,
+	}}
+	for _, test := range tests {
+		f := xpfunds.NewFund([]float64{1})
+		f.SetTaxRegime(test.regime)
+		if got, want := (BrazilianTax{}).Redemption(f, 1, test.value, test.paid, test.days), test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestPeriodic(t *testing.T) {
+	tests := []struct {
+		name   string
+		regime xpfunds.TaxRegime
+		value  float64
+		month  time.Month
+		want   float64
+	}{{
+		"may",
+		xpfunds.LongTerm,
+		1.1,
+		time.May,
+		0.015,
+	}, {
+		"november",
+		xpfunds.ShortTerm,
+		1.1,
+		time.November,
+		0.02,
+	}, {
+		"otherMonth",
+		xpfunds.LongTerm,
+		1.1,
+		time.June,
+		0,
+	}, {
+		"loss",
+		xpfunds.LongTerm,
+		0.9,
+		time.May,
+		0,
+	}, {
+		"equity",
+		xpfunds.Equity,
+		1.1,
+		time.May,
+		0,
+	}}
+	for _, test := range tests {
+		f := xpfunds.NewFund([]float64{1})
+		f.SetTaxRegime(test.regime)
+		if got, want := (BrazilianTax{}).Periodic(f, 1, test.value, test.month), test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestCalendarMonth(t *testing.T) {
+	tests := []struct {
+		last time.Month
+		t    int
+		want time.Month
+	}{
+		{time.January, 0, time.January},
+		{time.January, 1, time.December},
+		{time.December, 0, time.December},
+		{time.March, 14, time.January},
+	}
+	for _, test := range tests {
+		if got, want := calendarMonth(test.last, test.t), test.want; got != want {
+			t.Errorf("calendarMonth(%v, %v): got: %v, want: %v", test.last, test.t, got, want)
+		}
+	}
+}
+
+func TestPerformanceTax(t *testing.T) {
+	tests := []struct {
+		name      string
+		monthly   []float64
+		lastMonth time.Month
+		want      float64
+	}{{
+		"withoutComeCotas",
+		[]float64{1.1, 1.1, 1},
+		time.March,
+		1.21 - 0.21*0.225,
+	}, {
+		"comeCotas",
+		[]float64{1.1, 1.1, 1},
+		time.June,
+		1.085*1.1 - (1.085*1.1+0.015-1)*0.225 + 0.015,
+	}, {
+		// Come-cotas in May is over the gain since the investment, not only
+		// the gain of May, and isn't returned after the loss in June.
+		"comeCotasSinceLastCharge",
+		[]float64{0.5, 1.1, 1.1, 1.1, 1},
+		time.June,
+		(1.331 - 0.331*0.15) * 0.5,
+	}, {
+		// The loss in March isn't charged again when it's recovered in May.
+		"comeCotasAfterLoss",
+		[]float64{1.5, 1, 0.5, 1},
+		time.May,
+		0.75,
+	}}
+	for _, test := range tests {
+		b := &Backtest{
+			Funds:     newFunds([][]float64{test.monthly}),
+			Tax:       BrazilianTax{},
+			LastMonth: test.lastMonth,
+		}
+		months := len(test.monthly) - 1
+		got := math.Pow(performance(t, b, NewWeighted(0, weight(1, 1)), months), float64(months))
+		if want := test.want; !eq(got, want) {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package simulate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Optimizer finds the best strategy for a backtest.
+type Optimizer func(b *Backtest) (Strategy, error)
+
+// Window is one step of a walk forward.
+type Window struct {
+	// The strategy found by the optimizer in the train backtest.
+	Strategy Strategy
+
+	// The performance of Strategy in the backtest used to find it, and in the
+	// following months, which were not used to find it.
+	Train *Result
+	Test  *Result
+}
+
+// WalkForward finds a strategy with optimize using the choices in train months
+// and evaluates it in the choices in the following test months. Then it moves
+// test months forward and repeats until now. The choices in the test months
+// are made after all months used to evaluate the train choices. If Horizon is
+// 0, test is used as the horizon.
+//
+// It returns each step and the result of all test months together.
+func (b *Backtest) WalkForward(optimize Optimizer, train, test int) ([]*Window, *Result, error) {
+	horizon := b.Horizon
+	if horizon == 0 {
+		horizon = test
+	}
+	var windows []*Window
+	var dates []*Date
+	for trainStart := b.start(); ; trainStart -= test {
+		trainEnd := trainStart - train
+		testStart := trainEnd - horizon + 1
+		if testStart-1 < horizon || testStart-1 < 1 {
+			break
+		}
+		trainBacktest := b.window(trainStart, trainEnd, horizon)
+		s, err := optimize(trainBacktest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("optimizing from %v to %v: %w", trainStart, trainEnd, err)
+		}
+		w := &Window{Strategy: s}
+		if w.Train, err = trainBacktest.Run(w.Strategy); err != nil {
+			return nil, nil, err
+		}
+		if w.Test, err = b.window(testStart, testStart-test, horizon).Run(w.Strategy); err != nil {
+			return nil, nil, err
+		}
+		windows = append(windows, w)
+		dates = append(dates, w.Test.Dates...)
+	}
+	return windows, NewResult(dates), nil
+}
+
+// ParseWalk returns the train and test months of a walk forward written as
+// "train,test".
+func ParseWalk(s string) (train, test int, err error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("walk %q, want train,test", s)
+	}
+	if train, err = strconv.Atoi(fields[0]); err != nil {
+		return 0, 0, fmt.Errorf("walk %q: %v", s, err)
+	}
+	if test, err = strconv.Atoi(fields[1]); err != nil {
+		return 0, 0, fmt.Errorf("walk %q: %v", s, err)
+	}
+	if train < 1 || test < 1 {
+		return 0, 0, fmt.Errorf("walk %q, want positive months", s)
+	}
+	return train, test, nil
+}
+
+// Returns a copy of b with different choice times and horizon.
+func (b *Backtest) window(start, end, horizon int) *Backtest {
+	c := b.Clone()
+	c.Start = start
+	c.End = end
+	c.Horizon = horizon
+	return c
+}
+
+// Clone returns a copy of the settings of b, which can be changed without
+// affecting b. The benchmarks already calculated are shared while the funds
+// are the same.
+func (b *Backtest) Clone() *Backtest {
+	return &Backtest{
+		Funds:          b.Funds,
+		MaxDuration:    b.MaxDuration,
+		MinMonths:      b.MinMonths,
+		NumFunds:       b.NumFunds,
+		Start:          b.Start,
+		End:            b.End,
+		Horizon:        b.Horizon,
+		Rebalance:      b.Rebalance,
+		Cash:           b.Cash,
+		Tax:            b.Tax,
+		LastMonth:      b.LastMonth,
+		Capital:        b.Capital,
+		Contributions:  b.Contributions,
+		CDI:            b.CDI,
+		IPCA:           b.IPCA,
+		IPCASpread:     b.IPCASpread,
+		OnlyInvestable: b.OnlyInvestable,
+		Profile:        b.Profile,
+		Workers:        b.Workers,
+
+		benchmarks: b.benchmarkCache(),
+	}
+}
@@ -0,0 +1,114 @@
+package simulate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWalkForward(t *testing.T) {
+	b := &Backtest{
+		Funds: newFunds([][]float64{
+			{1.1, 0.9, 1.2, 1, 1.3, 0.8, 1, 1.1, 1.2, 0.9},
+			{1, 1.1, 0.9, 1.2, 1, 1.1, 1.2, 0.9, 1, 1.1},
+		}),
+		MaxDuration: 10,
+	}
+	best := NewWeighted(0, weight(1, 1))
+	worst := NewWeighted(0, weight(-1, 1))
+	var trains []*Backtest
+	optimize := func(train *Backtest) (Strategy, error) {
+		trains = append(trains, train)
+		if medianPerformance(t, train, best) >= medianPerformance(t, train, worst) {
+			return best, nil
+		}
+		return worst, nil
+	}
+	windows, r, err := b.WalkForward(optimize, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, train := range trains {
+		got = append(got, train.Start, train.End, train.Horizon)
+	}
+	if want := []int{10, 7, 2, 8, 5, 2}; !eqInts(got, want) {
+		t.Errorf("train backtests: got: %v, want: %v", got, want)
+	}
+	if got, want := len(windows), 2; got != want {
+		t.Fatalf("len(windows): got: %v, want: %v", got, want)
+	}
+	var times []int
+	for _, d := range r.Dates {
+		times = append(times, d.Time)
+	}
+	if want := []int{5, 4, 3, 2}; !eqInts(times, want) {
+		t.Errorf("test times: got: %v, want: %v", times, want)
+	}
+	for i, w := range windows {
+		trainPerf := w.Train.Median
+		other := best
+		if w.Strategy == best {
+			other = worst
+		}
+		if otherPerf := medianPerformance(t, trains[i], other); otherPerf > trainPerf {
+			t.Errorf("window %v: chose %v with %v, but %v had %v", i, w.Strategy.Name(), trainPerf, other.Name(), otherPerf)
+		}
+		// The test choices are made after the months used in the train.
+		lastTrain := w.Train.Dates[len(w.Train.Dates)-1].Time
+		if firstTest := w.Test.Dates[0].Time; lastTrain-2 < firstTest {
+			t.Errorf("window %v: train evaluated until %v, test starts at %v", i, lastTrain-2, firstTest)
+		}
+	}
+}
+
+func TestWalkForwardError(t *testing.T) {
+	b := &Backtest{
+		Funds:       newFunds([][]float64{{1.1, 0.9, 1.2, 1, 1.3, 0.8}, {1, 1.1, 0.9, 1.2, 1, 1.1}}),
+		MaxDuration: 6,
+	}
+	want := errors.New("no strategy")
+	optimize := func(*Backtest) (Strategy, error) { return nil, want }
+	if _, _, err := b.WalkForward(optimize, 2, 1); !errors.Is(err, want) {
+		t.Errorf("got: %v, want: %v", err, want)
+	}
+}
+
+func TestParseWalk(t *testing.T) {
+	tests := []struct {
+		s           string
+		train, test int
+		ok          bool
+	}{
+		{"36,12", 36, 12, true},
+		{"36", 0, 0, false},
+		{"36,12,1", 0, 0, false},
+		{"a,12", 0, 0, false},
+		{"36,0", 0, 0, false},
+	}
+	for _, test := range tests {
+		train, testMonths, err := ParseWalk(test.s)
+		if (err == nil) != test.ok || train != test.train || testMonths != test.test {
+			t.Errorf("%v: got: %v, %v, %v, want: %v, %v, ok %v", test.s, train, testMonths, err, test.train, test.test, test.ok)
+		}
+	}
+}
+
+func medianPerformance(t *testing.T, b *Backtest, s Strategy) float64 {
+	perf, err := b.MedianPerformance(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return perf
+}
+
+func eqInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
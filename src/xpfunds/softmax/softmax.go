@@ -0,0 +1,324 @@
+// Package softmax trains a model that scores each fund by a linear function
+// of its features and splits the money between the funds by the softmax of
+// the scores, to maximize their future return.
+package softmax
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"xpfunds"
+	"xpfunds/simulate"
+)
+
+// Model has a set of weights of the features for each slot. The share of a
+// fund is the average of its softmax in each slot. The model is a
+// simulate.Allocator.
+type Model struct {
+	// The months whose features are read. If 0, all months are read.
+	Months int
+	// The number of funds with the largest shares chosen. If 0, all are.
+	NumFunds int
+	// The weights of each feature in each slot, of the standardized features.
+	Slots [][]float64
+	// The mean and the standard deviation of each feature in the training,
+	// used to standardize them.
+	Mean, StdDev []float64
+}
+
+func (m *Model) Name() string {
+	return fmt.Sprintf("Softmax(%v,%v,%v)", m.Months, m.NumFunds, m.Slots)
+}
+
+// features returns the standardized features of f at end and whether f has
+// the months to read.
+func (m *Model) features(f *xpfunds.Fund, end int) ([]float64, bool) {
+	x, ok := rawFeatures(f, end, m.Months)
+	if !ok {
+		return nil, false
+	}
+	for i := range x {
+		x[i] = (x[i] - m.Mean[i]) / m.StdDev[i]
+	}
+	return x, true
+}
+
+func rawFeatures(f *xpfunds.Fund, end, months int) ([]float64, bool) {
+	start := end + months
+	if months == 0 {
+		start = f.Duration()
+	}
+	if !f.Exists(end) || start > f.Duration() || start <= end {
+		return nil, false
+	}
+	x := make([]float64, f.FeatureCount())
+	for i := range x {
+		x[i] = f.Feature(i, end, start)
+	}
+	return x, true
+}
+
+// shares returns the share of each fund in x by the features in x.
+func (m *Model) shares(x [][]float64) []float64 {
+	shares := make([]float64, len(x))
+	for _, w := range m.Slots {
+		for i, s := range softmax(scores(x, w)) {
+			shares[i] += s / float64(len(m.Slots))
+		}
+	}
+	return shares
+}
+
+func scores(x [][]float64, w []float64) []float64 {
+	z := make([]float64, len(x))
+	for i, features := range x {
+		for j, v := range features {
+			z[i] += v * w[j]
+		}
+	}
+	return z
+}
+
+func softmax(z []float64) []float64 {
+	max := math.Inf(-1)
+	for _, v := range z {
+		max = math.Max(max, v)
+	}
+	s := make([]float64, len(z))
+	total := 0.0
+	for i, v := range z {
+		s[i] = math.Exp(v - max)
+		total += s[i]
+	}
+	for i := range s {
+		s[i] /= total
+	}
+	return s
+}
+
+func (m *Model) Choose(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, error) {
+	chosen, _, err := m.Allocate(funds, end)
+	return chosen, err
+}
+
+// Allocate returns the funds with the largest shares and their shares, scaled
+// to add up to 1.
+func (m *Model) Allocate(funds []*xpfunds.Fund, end int) ([]*xpfunds.Fund, []float64, error) {
+	var candidates []*xpfunds.Fund
+	var x [][]float64
+	for _, f := range funds {
+		if features, ok := m.features(f, end); ok {
+			candidates = append(candidates, f)
+			x = append(x, features)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("%v at %v with %v funds: %w", m.Name(), end, len(funds), simulate.ErrNoFund)
+	}
+	shares := m.shares(x)
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return shares[order[i]] > shares[order[j]]
+	})
+	numFunds := m.NumFunds
+	if numFunds == 0 || numFunds > len(order) {
+		numFunds = len(order)
+	}
+	var chosen []*xpfunds.Fund
+	var chosenShares []float64
+	total := 0.0
+	for _, i := range order[:numFunds] {
+		chosen = append(chosen, candidates[i])
+		chosenShares = append(chosenShares, shares[i])
+		total += shares[i]
+	}
+	for i := range chosenShares {
+		chosenShares[i] /= total
+	}
+	return chosen, chosenShares, nil
+}
+
+// Trainer trains models by gradient ascent of the average future return of
+// the shares of the funds.
+type Trainer struct {
+	// The number of slots. If 0, it is 1.
+	Slots int
+	// Months and NumFunds of the models trained.
+	Months   int
+	NumFunds int
+	// The months of the future return of each choice. If 0, it's until the
+	// end of the training.
+	Horizon int
+	// If 0, they are 300 and 0.1.
+	Iterations   int
+	LearningRate float64
+	// The seed of the initial weights.
+	Seed int64
+}
+
+// ErrNoData is returned when there are no choices to train on.
+var ErrNoData = errors.New("no data to train on")
+
+// sample is the funds that can be chosen at a time, with their features and
+// future returns.
+type sample struct {
+	x [][]float64
+	y []float64
+}
+
+// Train trains a model with the choices whose future return is known at end:
+// those at times from end plus the horizon, up to the duration of the funds.
+func (t *Trainer) Train(funds []*xpfunds.Fund, end int) (*Model, float64, error) {
+	m := &Model{Months: t.Months, NumFunds: t.NumFunds}
+	samples := t.samples(funds, end)
+	if len(samples) == 0 {
+		return nil, 0, ErrNoData
+	}
+	featureCount := len(samples[0].x[0])
+	m.Mean, m.StdDev = standardize(samples, featureCount)
+
+	rnd := rand.New(rand.NewSource(t.Seed))
+	numSlots := t.Slots
+	if numSlots == 0 {
+		numSlots = 1
+	}
+	for i := 0; i < numSlots; i++ {
+		w := make([]float64, featureCount)
+		for j := range w {
+			// Truncated at 2 standard deviations.
+			for w[j] = rnd.NormFloat64(); math.Abs(w[j]) > 2; w[j] = rnd.NormFloat64() {
+			}
+		}
+		m.Slots = append(m.Slots, w)
+	}
+	iterations := t.Iterations
+	if iterations == 0 {
+		iterations = 300
+	}
+	rate := t.LearningRate
+	if rate == 0 {
+		rate = 0.1
+	}
+	for i := 0; i < iterations; i++ {
+		for k, g := range m.gradient(samples) {
+			for j := range g {
+				m.Slots[k][j] += rate * g[j]
+			}
+		}
+	}
+	return m, m.objective(samples), nil
+}
+
+func (t *Trainer) samples(funds []*xpfunds.Fund, end int) []*sample {
+	var samples []*sample
+	first := end + t.Horizon
+	if t.Horizon == 0 {
+		first = end + 1
+	}
+	for time := first; time < xpfunds.MaxDuration(funds); time++ {
+		horizon := t.Horizon
+		if horizon == 0 {
+			horizon = time - end
+		}
+		s := &sample{}
+		for _, f := range funds {
+			if !f.Exists(time - horizon) {
+				continue
+			}
+			x, ok := rawFeatures(f, time, t.Months)
+			if !ok {
+				continue
+			}
+			y := 1.0
+			for m := time - 1; m >= time-horizon; m-- {
+				y *= f.MonthlyReturn(m)
+			}
+			s.x = append(s.x, x)
+			s.y = append(s.y, math.Pow(y, 1/float64(horizon)))
+		}
+		if len(s.x) > 0 {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// standardize changes the features of the samples to have mean 0 and
+// standard deviation 1, returning the original ones.
+func standardize(samples []*sample, featureCount int) ([]float64, []float64) {
+	mean := make([]float64, featureCount)
+	stdDev := make([]float64, featureCount)
+	n := 0.0
+	for _, s := range samples {
+		for _, x := range s.x {
+			for i, v := range x {
+				mean[i] += v
+			}
+			n++
+		}
+	}
+	for i := range mean {
+		mean[i] /= n
+	}
+	for _, s := range samples {
+		for _, x := range s.x {
+			for i, v := range x {
+				stdDev[i] += (v - mean[i]) * (v - mean[i])
+			}
+		}
+	}
+	for i := range stdDev {
+		stdDev[i] = math.Sqrt(stdDev[i] / n)
+		// A constant feature doesn't change the scores.
+		if stdDev[i] == 0 {
+			stdDev[i] = 1
+		}
+	}
+	for _, s := range samples {
+		for _, x := range s.x {
+			for i := range x {
+				x[i] = (x[i] - mean[i]) / stdDev[i]
+			}
+		}
+	}
+	return mean, stdDev
+}
+
+// objective returns the average over the samples of the future return of the
+// shares of all funds.
+func (m *Model) objective(samples []*sample) float64 {
+	total := 0.0
+	for _, s := range samples {
+		for i, share := range m.shares(s.x) {
+			total += share * s.y[i]
+		}
+	}
+	return total / float64(len(samples))
+}
+
+// gradient returns the gradient of the objective by the weights of each slot.
+func (m *Model) gradient(samples []*sample) [][]float64 {
+	gradient := make([][]float64, len(m.Slots))
+	for k, w := range m.Slots {
+		gradient[k] = make([]float64, len(w))
+		for _, s := range samples {
+			shares := softmax(scores(s.x, w))
+			expected := 0.0
+			for i, share := range shares {
+				expected += share * s.y[i]
+			}
+			for i, share := range shares {
+				d := share * (s.y[i] - expected) / float64(len(m.Slots)) / float64(len(samples))
+				for j, v := range s.x[i] {
+					gradient[k][j] += d * v
+				}
+			}
+		}
+	}
+	return gradient
+}
@@ -0,0 +1,100 @@
+package softmax
+
+import (
+	"math"
+	"testing"
+	"xpfunds"
+)
+
+func newFunds(monthly [][]float64) []*xpfunds.Fund {
+	var funds []*xpfunds.Fund
+	for _, m := range monthly {
+		funds = append(funds, xpfunds.NewFund(m))
+	}
+	return funds
+}
+
+func TestSoftmax(t *testing.T) {
+	for _, test := range []struct {
+		z, want []float64
+	}{
+		{[]float64{0, 0}, []float64{0.5, 0.5}},
+		{[]float64{math.Log(3), 0}, []float64{0.75, 0.25}},
+		{[]float64{1000, 1000, 1000, 1000}, []float64{0.25, 0.25, 0.25, 0.25}},
+	} {
+		got := softmax(test.z)
+		for i := range got {
+			if math.Abs(got[i]-test.want[i]) > 1e-9 {
+				t.Errorf("%v: got: %v, want: %v", test.z, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestGradient(t *testing.T) {
+	samples := []*sample{
+		{x: [][]float64{{1, -1}, {-1, 0.5}, {0, 2}}, y: []float64{1.1, 0.9, 1.0}},
+		{x: [][]float64{{0.5, 0}, {-0.5, 1}}, y: []float64{0.95, 1.05}},
+	}
+	m := &Model{Slots: [][]float64{{0.3, -0.2}, {-0.1, 0.4}}}
+	gradient := m.gradient(samples)
+	const h = 1e-6
+	for k := range m.Slots {
+		for j := range m.Slots[k] {
+			w := m.Slots[k][j]
+			m.Slots[k][j] = w + h
+			plus := m.objective(samples)
+			m.Slots[k][j] = w - h
+			minus := m.objective(samples)
+			m.Slots[k][j] = w
+			want := (plus - minus) / (2 * h)
+			if math.Abs(gradient[k][j]-want) > 1e-6 {
+				t.Errorf("%v,%v: got: %v, want: %v", k, j, gradient[k][j], want)
+			}
+		}
+	}
+}
+
+func TestTrain(t *testing.T) {
+	// The fund that returned the most in the past keeps doing so.
+	var monthly [][]float64
+	for i := 0; i < 4; i++ {
+		var m []float64
+		for time := 0; time < 24; time++ {
+			m = append(m, 1+float64(i)/100)
+		}
+		monthly = append(monthly, m)
+	}
+	funds := newFunds(monthly)
+	trainer := &Trainer{Months: 3, NumFunds: 2, Horizon: 1}
+	m, objective, err := trainer.Train(funds, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An equal split returns 1.015.
+	if objective <= 1.015 {
+		t.Errorf("objective: got: %v, want: > 1.015", objective)
+	}
+	chosen, shares, err := m.Allocate(funds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chosen) != 2 || chosen[0] != funds[3] {
+		t.Errorf("chosen: got: %v, want: %v first of 2", chosen, funds[3])
+	}
+	total := 0.0
+	for _, s := range shares {
+		total += s
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("shares: got: %v, want: sum 1", shares)
+	}
+}
+
+func TestTrainNoData(t *testing.T) {
+	funds := newFunds([][]float64{{1, 1, 1}})
+	if _, _, err := (&Trainer{Months: 3, Horizon: 1}).Train(funds, 0); err != ErrNoData {
+		t.Errorf("got: %v, want: %v", err, ErrNoData)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"os"
 	"strconv"
 	"strings"
 	"xpfunds/binarysearch"
@@ -11,20 +12,82 @@ import (
 	"xpfunds/median"
 )
 
+// TaxRegime determines how the income tax of a fund is charged.
+type TaxRegime int
+
+const (
+	// Pays the regressive income tax, from 22.5% to 15%, and come-cotas at
+	// 15%.
+	LongTerm TaxRegime = iota
+
+	// Pays 22.5% or 20% of income tax and come-cotas at 20%.
+	ShortTerm
+
+	// Pays 15% of income tax, without come-cotas and IOF.
+	Equity
+)
+
+// Profile is the kind of investor, which determines the funds they can buy.
+type Profile int
+
+const (
+	Retail Profile = iota
+	Qualified
+	Professional
+)
+
+// ParseProfile returns the profile named retail, qualified or professional.
+func ParseProfile(name string) (Profile, error) {
+	switch name {
+	case "retail":
+		return Retail, nil
+	case "qualified":
+		return Qualified, nil
+	case "professional":
+		return Professional, nil
+	}
+	return Retail, fmt.Errorf("unknown profile %q, want retail, qualified or professional", name)
+}
+
 type Fund struct {
 	name string
 
+	// "true" if the fund accepts investments from retail investors,
+	// "qualified" or "professional" if it only accepts investments from these
+	// investors and "false" if it doesn't accept investments.
 	active string
 
+	// Whether the fund doesn't accept new investments now.
+	closed bool
+
+	// Whether the fund accepted new investments at each time, when known.
+	availability []bool
+
+	// The investors that can buy the fund.
+	profile Profile
+
 	min string
 
+	// min as a number.
+	minimum float64
+
+	// The number of days we need to wait to get the money in a withdraw,
+	// cotização plus liquidação.
+	days int
+
+	regime TaxRegime
+
 	// The monthly return of the fund, starting from the last month.
 	monthly []float64
 
+	// The time of the last month of the fund. Greater than 0 if the fund was
+	// delisted.
+	end int
+
 	// The position of the first slice determines the dimension. The position of
-	// the second slice indicates an end time of a period and the third position
-	// the difference from the start time to the end time of a period. Arbitrary
-	// range.
+	// the second slice indicates an end time of a period, relative to the end
+	// of the fund, and the third position the difference from the start time to
+	// the end time of a period. Arbitrary range.
 	features [][][]float64
 
 	// Same as fieldValues, but holds the ratio of the value in this fund to the
@@ -41,12 +104,23 @@ func NewFund(monthly []float64) *Fund {
 	return f
 }
 
+// featureSetters set the features of a fund, in order, with the names of the
+// features each one appends.
+var featureSetters = []struct {
+	names []string
+	set   func(f *Fund)
+}{
+	{[]string{"return"}, (*Fund).setReturn},
+	{[]string{"stdDev"}, (*Fund).setStdDev},
+	{[]string{"negativeMonthRatio"}, (*Fund).setNegativeMonthRatio},
+	{[]string{"median"}, (*Fund).setMedian},
+	{[]string{"greatestFall", "greatestFallLen"}, (*Fund).setGreatestFall},
+}
+
 func (f *Fund) setFeatures() {
-	f.setReturn()
-	f.setStdDev()
-	f.setNegativeMonthRatio()
-	f.setMedian()
-	f.setGreatestFall()
+	for _, s := range featureSetters {
+		s.set(f)
+	}
 }
 
 func (f *Fund) setReturn() {
@@ -145,16 +219,26 @@ func (f *Fund) setGreatestFall() {
 	f.features = append(f.features, gf, gfl)
 }
 
+// Copy returns a copy of f with its own ratios, so that SetRatio can be called
+// on a different set of funds with the copy.
+func (f *Fund) Copy() *Fund {
+	c := *f
+	c.makeRatio()
+	return &c
+}
+
 func (f *Fund) makeRatio() {
 	f.ratio = make([][][]float64, f.FeatureCount())
 	for feature := range f.ratio {
-		f.ratio[feature] = make([][]float64, f.Duration())
+		f.ratio[feature] = make([][]float64, len(f.monthly))
 		for end := range f.ratio[feature] {
-			f.ratio[feature][end] = make([]float64, f.Duration()-end)
+			f.ratio[feature][end] = make([]float64, len(f.monthly)-end)
 		}
 	}
 }
 
+// ReadFunds reads the funds in get.tsv and the delisted funds in
+// delisted.tsv, if it exists.
 func ReadFunds() []*Fund {
 	text, err := ioutil.ReadFile("get.tsv")
 	check.Check(err)
@@ -166,10 +250,38 @@ func ReadFunds() []*Fund {
 		}
 		funds = append(funds, f)
 	}
+	funds = append(funds, readDelisted("delisted.tsv")...)
 	SetRatio(funds)
 	return funds
 }
 
+// Each line of file has the time of the last month of a delisted fund,
+// followed by the fields in the format of get.tsv, with the monthly returns
+// starting from the last month of the fund. These can come from an older
+// get.tsv or from CVM data.
+func readDelisted(file string) []*Fund {
+	text, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	check.Check(err)
+	var funds []*Fund
+	for _, line := range strings.Split(string(text), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) < 2 {
+			continue
+		}
+		f := fundFromLine(fields[1])
+		if f == nil {
+			continue
+		}
+		f.end, err = strconv.Atoi(fields[0])
+		check.Check(err)
+		funds = append(funds, f)
+	}
+	return funds
+}
+
 func fundFromLine(line string) *Fund {
 	fields := strings.Split(strings.Trim(line, "\n"), "\t")
 	if len(fields) < 6 {
@@ -185,27 +297,183 @@ func fundFromLine(line string) *Fund {
 	f := NewFund(monthly)
 	f.name = fields[0]
 	f.active = fields[4]
+	f.setProfile()
 	f.min = fields[1]
+	f.setMinimum()
+	f.setDays(fields)
+	f.setTaxRegime()
 	return f
 }
 
+func (f *Fund) setProfile() {
+	switch f.active {
+	case "true":
+	case "qualified":
+		f.profile = Qualified
+	case "professional":
+		f.profile = Professional
+	default:
+		f.closed = true
+	}
+}
+
+// XP doesn't show the tax regime of the fund, so equity funds are recognized
+// by the name.
+func (f *Fund) setTaxRegime() {
+	if strings.Contains(f.name, "Ações") || strings.Contains(f.name, " FIA") {
+		f.regime = Equity
+	}
+}
+
+// min is in the format 1.000,00.
+func (f *Fund) setMinimum() {
+	var err error
+	f.minimum, err = strconv.ParseFloat(strings.Replace(strings.Replace(f.min, ".", "", -1), ",", ".", 1), 64)
+	check.Check(err)
+}
+
+func (f *Fund) setDays(fields []string) {
+	cot, err := strconv.Atoi(fields[2])
+	check.Check(err)
+	liq, err := strconv.Atoi(fields[3])
+	check.Check(err)
+	f.days = cot + liq
+}
+
+// FundFromFile reads an index, like cdi.tsv, with one monthly return in
+// percentage per line, starting from the last month.
+func FundFromFile(file string) *Fund {
+	text, err := ioutil.ReadFile(file)
+	check.Check(err)
+	var monthly []float64
+	for _, line := range strings.Split(string(text), "\n") {
+		v, err := strconv.ParseFloat(strings.Replace(line, ",", ".", 1), 64)
+		if err != nil {
+			break
+		}
+		monthly = append(monthly, 1.0+v/100.0)
+	}
+	return NewFund(monthly)
+}
+
+// FeatureNames are the names of the features of the funds, in the order of
+// their weights.
+var FeatureNames = featureNames()
+
+func featureNames() []string {
+	var names []string
+	for _, s := range featureSetters {
+		names = append(names, s.names...)
+	}
+	return names
+}
+
 func (f *Fund) FeatureCount() int {
 	return len(f.features)
 }
 
+// Duration is the time of the first month of the fund plus 1. For funds that
+// were not delisted, this is the number of months of the fund.
 func (f *Fund) Duration() int {
-	return len(f.monthly)
+	return f.end + len(f.monthly)
+}
+
+// End is the time of the last month of the fund.
+func (f *Fund) End() int {
+	return f.end
+}
+
+func (f *Fund) SetEnd(end int) {
+	f.end = end
+}
+
+// Exists returns whether the fund had a return in the month at time.
+func (f *Fund) Exists(time int) bool {
+	return time >= f.end && time < f.Duration()
+}
+
+// MonthlyReturn is the return of the fund in the month at time, as a factor
+// (1.01 for 1%).
+func (f *Fund) MonthlyReturn(time int) float64 {
+	return f.monthly[time-f.end]
+}
+
+func (f *Fund) Name() string {
+	return f.name
+}
+
+func (f *Fund) SetName(name string) {
+	f.name = name
+}
+
+// Minimum is the minimum value for the first investment in the fund.
+func (f *Fund) Minimum() float64 {
+	return f.minimum
+}
+
+func (f *Fund) SetMinimum(minimum float64) {
+	f.minimum = minimum
+}
+
+// Investable returns whether an investor with profile could buy the fund at
+// time. If it's not known whether the fund accepted new investments at time,
+// it's assumed it did if it does now. A fund that doesn't exist at time isn't
+// investable.
+func (f *Fund) Investable(profile Profile, time int) bool {
+	if !f.Exists(time) || profile < f.profile {
+		return false
+	}
+	if time < len(f.availability) {
+		return f.availability[time]
+	}
+	return !f.closed
+}
+
+func (f *Fund) SetClosed(closed bool) {
+	f.closed = closed
+}
+
+// SetAvailability sets whether the fund accepted new investments at each
+// time, starting from time 0.
+func (f *Fund) SetAvailability(availability []bool) {
+	f.availability = availability
+}
+
+func (f *Fund) SetProfile(profile Profile) {
+	f.profile = profile
+}
+
+func (f *Fund) Days() int {
+	return f.days
+}
+
+func (f *Fund) SetDays(days int) {
+	f.days = days
+}
+
+func (f *Fund) TaxRegime() TaxRegime {
+	return f.regime
+}
+
+func (f *Fund) SetTaxRegime(regime TaxRegime) {
+	f.regime = regime
 }
 
 // End is inclusive, start is exclusive
 func (f *Fund) Weighted(weight []float64, end, start int) float64 {
 	total := 0.0
 	for i, w := range weight {
-		total += f.ratio[i][end][start-1-end] * w
+		total += f.ratio[i][end-f.end][start-1-end] * w
 	}
 	return total
 }
 
+// Feature returns the value of a feature in the months from end, inclusive, to
+// start, exclusive.
+func (f *Fund) Feature(feature, end, start int) float64 {
+	return f.features[feature][end-f.end][start-1-end]
+}
+
 func (f *Fund) Return(end, start int) float64 {
 	return f.Weighted([]float64{1}, end, start)
 }
@@ -221,28 +489,39 @@ func SetRatio(funds []*Fund) {
 			for diff := 0; diff < duration-end; diff++ {
 				highest := -999999.99
 				for _, f := range funds {
-					if f.Duration() <= end+diff {
+					if !f.Exists(end) || !f.Exists(end+diff) {
 						continue
 					}
-					if f.features[feature][end][diff] > highest {
-						highest = f.features[feature][end][diff]
+					if f.features[feature][end-f.end][diff] > highest {
+						highest = f.features[feature][end-f.end][diff]
 					}
 				}
 				for _, f := range funds {
-					if f.Duration() <= end+diff {
+					if !f.Exists(end) || !f.Exists(end+diff) {
 						continue
 					}
 					if highest == 0 {
-						f.ratio[feature][end][diff] = 1
+						f.ratio[feature][end-f.end][diff] = 1
 						continue
 					}
-					f.ratio[feature][end][diff] = f.features[feature][end][diff] / highest
+					f.ratio[feature][end-f.end][diff] = f.features[feature][end-f.end][diff] / highest
 				}
 			}
 		}
 	}
 }
 
+// Investable returns the funds an investor with profile could buy at time.
+func Investable(funds []*Fund, profile Profile, time int) []*Fund {
+	var investable []*Fund
+	for _, f := range funds {
+		if f.Investable(profile, time) {
+			investable = append(investable, f)
+		}
+	}
+	return investable
+}
+
 func MaxDuration(funds []*Fund) int {
 	duration := 0
 	for _, f := range funds {
@@ -78,6 +78,155 @@ func TestWeighted(t *testing.T) {
 	}
 }
 
+func TestDelisted(t *testing.T) {
+	delisted := NewFund([]float64{1.2})
+	delisted.SetEnd(1)
+	funds := []*Fund{delisted, NewFund([]float64{1, 1.1})}
+	SetRatio(funds)
+	if got, want := delisted.Duration(), 2; got != want {
+		t.Errorf("Duration: got: %v, want: %v", got, want)
+	}
+	if delisted.Exists(0) || !delisted.Exists(1) {
+		t.Errorf("Exists: got: %v, %v, want: false, true", delisted.Exists(0), delisted.Exists(1))
+	}
+	if got, want := delisted.MonthlyReturn(1), 1.2; !eq(got, want) {
+		t.Errorf("MonthlyReturn: got: %v, want: %v", got, want)
+	}
+	if got, want := delisted.Return(1, 2), 1.0; !eq(got, want) {
+		t.Errorf("Return: got: %v, want: %v", got, want)
+	}
+	if got, want := funds[1].Return(1, 2), 1.1/1.2; !eq(got, want) {
+		t.Errorf("Return: got: %v, want: %v", got, want)
+	}
+	if got, want := funds[1].Return(0, 1), 1.0; !eq(got, want) {
+		t.Errorf("Return: got: %v, want: %v", got, want)
+	}
+}
+
+func TestInvestable(t *testing.T) {
+	tests := []struct {
+		name         string
+		fundProfile  Profile
+		closed       bool
+		availability []bool
+		profile      Profile
+		time         int
+		want         bool
+	}{{
+		"retail",
+		Retail,
+		false,
+		nil,
+		Retail,
+		0,
+		true,
+	}, {
+		"closed",
+		Retail,
+		true,
+		nil,
+		Retail,
+		0,
+		false,
+	}, {
+		"qualifiedOnly",
+		Qualified,
+		false,
+		nil,
+		Retail,
+		0,
+		false,
+	}, {
+		"qualified",
+		Qualified,
+		false,
+		nil,
+		Professional,
+		0,
+		true,
+	}, {
+		"closedBefore",
+		Retail,
+		false,
+		[]bool{true, false},
+		Retail,
+		1,
+		false,
+	}, {
+		"openBefore",
+		Retail,
+		true,
+		[]bool{false, true},
+		Retail,
+		1,
+		true,
+	}, {
+		"unknownBefore",
+		Retail,
+		true,
+		[]bool{true},
+		Retail,
+		1,
+		false,
+	}}
+	for _, test := range tests {
+		f := NewFund([]float64{1, 1})
+		f.SetProfile(test.fundProfile)
+		f.SetClosed(test.closed)
+		f.SetAvailability(test.availability)
+		if got, want := f.Investable(test.profile, test.time), test.want; got != want {
+			t.Errorf("%v: got: %v, want: %v", test.name, got, want)
+		}
+	}
+}
+
+func TestFeatureNames(t *testing.T) {
+	f := NewFund([]float64{1.1, 0.9, 1})
+	if got, want := len(FeatureNames), f.FeatureCount(); got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	for _, s := range featureSetters {
+		before := len(f.features)
+		s.set(f)
+		if got, want := len(f.features)-before, len(s.names); got != want {
+			t.Errorf("%v: got: %v features, want: %v", s.names, got, want)
+		}
+	}
+}
+
+func TestInvestableDelisted(t *testing.T) {
+	f := NewFund([]float64{1, 1})
+	f.SetEnd(1)
+	funds := []*Fund{NewFund([]float64{1, 1}), f}
+	if f.Investable(Retail, 0) {
+		t.Errorf("at 0: got: true, want: false")
+	}
+	if !f.Investable(Retail, 1) {
+		t.Errorf("at 1: got: false, want: true")
+	}
+	if got := Investable(funds, Retail, 0); len(got) != 1 || got[0] != funds[0] {
+		t.Errorf("Investable: got: %v, want: %v", got, funds[:1])
+	}
+}
+
 func eq(a, b float64) bool {
 	return math.Abs(a-b) < 0.000001
 }
+
+func TestParseProfile(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want Profile
+	}{
+		{"retail", Retail},
+		{"qualified", Qualified},
+		{"professional", Professional},
+	} {
+		if got, err := ParseProfile(test.name); err != nil || got != test.want {
+			t.Errorf("%v: got: %v, %v, want: %v", test.name, got, err, test.want)
+		}
+	}
+	if _, err := ParseProfile("Retail"); err == nil {
+		t.Error("Retail: got no error")
+	}
+}
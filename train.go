@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/simulate"
+	"xpfunds/softmax"
+)
+
+var (
+	eval         = flag.Int("eval", 56, "How many of the most recent months are held out of the training")
+	numFunds     = flag.Int("numFunds", 5, "How many funds the model chooses")
+	months       = flag.Int("months", 0, "How many months the model reads, 0 for all")
+	slots        = flag.Int("slots", 5, "How many sets of weights are averaged")
+	horizon      = flag.Int("horizon", 0, "How many months each training choice is evaluated for, 0 for until the evaluation")
+	iterations   = flag.Int("iterations", 300, "How many steps of gradient ascent")
+	learningRate = flag.Float64("learningRate", 0.1, "The size of each step")
+	seed         = flag.Int64("seed", 1, "The seed of the initial weights")
+)
+
+func main() {
+	flag.Parse()
+	funds := xpfunds.ReadFunds()
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	trainer := &softmax.Trainer{
+		Slots:        *slots,
+		Months:       *months,
+		NumFunds:     *numFunds,
+		Horizon:      *horizon,
+		Iterations:   *iterations,
+		LearningRate: *learningRate,
+		Seed:         *seed,
+	}
+	m, objective, err := trainer.Train(funds, *eval)
+	check.Check(err)
+	fmt.Println("model:", m.Name())
+	fmt.Println("train:", objective)
+	b := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		MinMonths:   *months,
+		NumFunds:    *numFunds,
+		Start:       *eval,
+		Cash:        cdi,
+		CDI:         cdi,
+	}
+	fmt.Println("strategy\tmedian\tmean\tworst\tbeatCDI")
+	for _, s := range []simulate.Strategy{m, simulate.AllFunds{}} {
+		r, err := b.Run(s)
+		if err != nil {
+			fmt.Printf("%v\t%v\n", s.Name(), err)
+			continue
+		}
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", s.Name(), r.Median, r.Mean, r.Worst, r.BeatCDI)
+	}
+}
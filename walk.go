@@ -1,60 +1,148 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math/rand"
-	"time"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/manifest"
+	"xpfunds/optimize"
+	"xpfunds/search"
 	"xpfunds/simulate"
 )
 
 var (
-	funds       []*xpfunds.Fund
-	maxDuration int
-	maxMonths   = 60
-	numFunds    = 1
+	seed         = flag.Int64("seed", 1, "The seed of the random numbers")
+	manifestFile = flag.String("manifest", "walk_manifest.json", "Where to write the description of the run")
+	iterations   = flag.Int("iterations", 0, "The maximum number of iterations, or 0 for no limit")
+	duration     = flag.Duration("duration", 0, "The maximum time running, or 0 for no limit")
+	patience     = flag.Int("patience", 0, "The maximum number of iterations without improvement, or 0 for no limit")
+	checkpoint   = flag.String("checkpoint", "walk_checkpoint.json", "Where to write the state of the search, to be resumed")
+	every        = flag.Int("every", 1, "The number of iterations between checkpoints")
+	resume       = flag.Bool("resume", false, "Whether to continue the search from the checkpoint")
+	quantum      = flag.Float64("quantum", 0.001, "The weights are rounded to multiples of this before being evaluated")
+	objective    = flag.String("objective", "relativeCDI", "What the search maximizes: median or relativeCDI")
+	walk         = flag.String("walk", "", "If set to train,test months, the search is also walked forward and its result in the test months printed next to the result in all months")
+	profileName  = flag.String("profile", "retail", "The investor whose investable funds are chosen: retail, qualified or professional")
+	numFunds     = flag.Int("numFunds", 1, "The number of funds held")
+	maxMonths    = flag.Int("maxMonths", 60, "The most months the strategy can read")
+	minMonths    = flag.Int("minMonths", 120, "The least months the funds need to be chosen")
 )
 
+var rebalance = 0
+
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	funds = xpfunds.ReadFunds()
-	for _, f := range funds {
-		if f.Duration() > maxDuration {
-			maxDuration = f.Duration()
+	flag.Parse()
+	profile, err := xpfunds.ParseProfile(*profileName)
+	check.Check(err)
+	value, err := search.ParseValue(*objective)
+	check.Check(err)
+	var train, test int
+	if *walk != "" {
+		train, test, err = simulate.ParseWalk(*walk)
+		check.Check(err)
+	}
+	source := optimize.NewSource(*seed)
+	runSeed := *seed
+	var resumed *optimize.Checkpoint
+	if *resume {
+		var err error
+		resumed, err = optimize.ReadCheckpoint(*checkpoint)
+		check.Check(err)
+		source = resumed.Source()
+		// The random numbers continue from the seed of the checkpoint.
+		runSeed = resumed.Seed
+	}
+	manifest.New(runSeed, "get.tsv", "delisted.tsv", "cdi.tsv").Write(*manifestFile)
+	rnd := rand.New(source)
+	funds := xpfunds.ReadFunds()
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	backtest := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		Rebalance:   rebalance,
+		Cash:        cdi,
+		CDI:         cdi,
+
+		OnlyInvestable: true,
+		Profile:        profile,
+	}
+	space := &search.Space{
+		MinNumFunds:  *numFunds,
+		MaxNumFunds:  *numFunds,
+		MinMaxMonths: *maxMonths,
+		MaxMaxMonths: *maxMonths,
+		MinMinMonths: *minMonths,
+		MaxMinMonths: *minMonths,
+		FeatureCount: funds[0].FeatureCount(),
+	}
+	s := search.NewSearch(space, backtest, *quantum, value)
+	point := make([]float64, space.Dimension())
+	// When resuming, the point is in the checkpoint and the random numbers
+	// were already drawn.
+	if resumed == nil {
+		for i := range point {
+			point[i] = rnd.Float64()*2 - 1
 		}
 	}
-	point := make([]float64, (funds[0].FeatureCount()+(&simulate.Weighted{}).FeatureCount())*numFunds)
-	for i := range point {
-		point[i] = rand.Float64()*2 - 1
+	stop := optimize.Stop{
+		Iterations:    *iterations,
+		Duration:      *duration,
+		NoImprovement: *patience,
+	}
+	optimizer := &optimize.Perturb{Rand: rnd}
+	r, err := optimizer.Optimize(&optimize.Problem{
+		Objective:       s.Objective,
+		Start:           point,
+		Bounds:          optimize.Weights,
+		Stop:            stop,
+		Checkpoint:      *checkpoint,
+		CheckpointEvery: *every,
+		Source:          source,
+		Resume:          resumed,
+		Progress: func(p *optimize.Progress) {
+			fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", p.Iteration, p.Value, p.Duration.String(), p.Point, p.Step, s)
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
-	step := 1.0
-	for i := 0; true; i++ {
-		start := time.Now()
-		best, perf := bestInRegion(point)
-		end := time.Now()
-		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", i, perf, end.Sub(start).String(), best, step)
-		point = best
+	if *walk == "" {
+		return
 	}
+	// The test months are simulated with the structure of the space.
+	wb := backtest.Clone()
+	wb.NumFunds = *numFunds
+	wb.MinMonths = *minMonths
+	start := make([]float64, space.Dimension())
+	for i := range start {
+		start[i] = rnd.Float64()*2 - 1
+	}
+	windows, out, err := wb.WalkForward(search.Optimizer(space, optimizer, &optimize.Problem{
+		Start:  start,
+		Bounds: optimize.Weights,
+		Stop:   stop,
+	}, *quantum, value), train, test)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("window\ttrainMedian\ttestMedian\tstrategy")
+	for i, w := range windows {
+		fmt.Printf("%v\t%v\t%v\t%v\n", i, w.Train.Median, w.Test.Median, w.Strategy.Name())
+	}
+	// The best strategy of all months, in the same backtest as the test months.
+	c, err := space.Decode(r.Point)
+	check.Check(err)
+	in, err := wb.Run(c.Strategy())
+	check.Check(err)
+	fmt.Println("sample\tmedian\tmean\tworst\tbeatCDI\trelativeCDI")
+	printResult("in", in)
+	printResult("out", out)
 }
 
-func bestInRegion(point []float64) ([]float64, float64) {
-	newPoint := make([]float64, len(point))
-	for i, p := range point {
-		newPoint[i] = p
-	}
-	bestPerf := simulate.MedianPerformance(funds, maxDuration, maxMonths*2, numFunds, simulate.NewWeighted(maxMonths, newPoint))
-	for i := 0; i < len(newPoint); i++ {
-		step := rand.Float64()*2 - 1
-		if newPoint[i]+step <= -1 || newPoint[i]+step >= 1 {
-			continue
-		}
-		newPoint[i] += step
-		perf := simulate.MedianPerformance(funds, maxDuration, maxMonths*2, numFunds, simulate.NewWeighted(maxMonths, newPoint))
-		if perf > bestPerf {
-			bestPerf = perf
-			continue
-		}
-		newPoint[i] -= step
-	}
-	return newPoint, bestPerf
+func printResult(sample string, r *simulate.Result) {
+	fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", sample, r.Median, r.Mean, r.Worst, r.BeatCDI, r.MedianRelative(simulate.CDI))
 }
@@ -1,70 +1,113 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"xpfunds"
+	"xpfunds/check"
+	"xpfunds/manifest"
+	"xpfunds/optimize"
+	"xpfunds/search"
 	"xpfunds/simulate"
 )
 
 var (
-	monthsToRead  = 0
-	numFunds      = 10
-	maxMinMonths  = 2
-	stepMinMonths = 1
-	step          = 1.0
-	print         = true
+	manifestFile = flag.String("manifest", "weights_manifest.json", "Where to write the description of the run")
+	checkpoint   = flag.String("checkpoint", "weights_checkpoint.json", "Where to write the state of the search, to be resumed")
+	every        = flag.Int("every", 1, "The number of iterations between checkpoints")
+	resume       = flag.Bool("resume", false, "Whether to continue the search from the checkpoint")
+	objective    = flag.String("objective", "relativeCDI", "What the search maximizes: median or relativeCDI")
+	profileName  = flag.String("profile", "retail", "The investor whose investable funds are chosen: retail, qualified or professional")
+	numFunds     = flag.Int("numFunds", 10, "The number of funds held")
+	maxMonths    = flag.Int("maxMonths", 2, "The most months the strategy can read")
+	minMonths    = flag.Int("minMonths", 2, "The least months the funds need to be chosen")
+)
+
+var (
+	step      = 1.0
+	print     = true
+	rebalance = 0
 )
 
 func main() {
+	flag.Parse()
+	profile, err := xpfunds.ParseProfile(*profileName)
+	check.Check(err)
+	value, err := search.ParseValue(*objective)
+	check.Check(err)
+	var resumed *optimize.Checkpoint
+	if *resume {
+		var err error
+		resumed, err = optimize.ReadCheckpoint(*checkpoint)
+		check.Check(err)
+	}
+	manifest.New(0, "get.tsv", "delisted.tsv", "cdi.tsv").Write(*manifestFile)
 	funds := xpfunds.ReadFunds()
-	maxDuration := 0
-	for _, f := range funds {
-		if f.Duration() > maxDuration {
-			maxDuration = f.Duration()
-		}
+	cdi := xpfunds.FundFromFile("cdi.tsv")
+	backtest := &simulate.Backtest{
+		Funds:       funds,
+		MaxDuration: xpfunds.MaxDuration(funds),
+		Rebalance:   rebalance,
+		Cash:        cdi,
+		CDI:         cdi,
+
+		OnlyInvestable: true,
+		Profile:        profile,
 	}
-	c := make(chan bool)
-	n := 0
-	for monthsToRead := 0; monthsToRead <= 0; monthsToRead += 1 {
-		for minMonths := 2; minMonths <= 2; minMonths += 1 {
-			for ret := 0.15625; ret <= 0.15625; ret += step {
-				n++
-				go func(ret float64, monthsToRead, minMonths int) {
-					for median := 0.28125; median <= 0.28125; median += step {
-						for stdDev := 0.8125; stdDev <= 0.8125; stdDev += step {
-							for nmr := -0.46875; nmr <= -0.46875; nmr += step {
-								for gf := 0.0; gf <= -0.0; gf += step {
-									for gfl := -0.28125; gfl <= -0.28125; gfl += step {
-										weight := []float64{
-											ret,
-											median,
-											stdDev,
-											nmr,
-											gf,
-											gfl,
-											-1,
-											-1,
-										}
-										s := simulate.NewWeighted(maxMinMonths, weight)
-										p := simulate.MedianPerformance(funds, maxDuration, maxMinMonths, numFunds, s)
-										fmt.Printf("%v\t%v\n", s.Name(), p)
-										if print {
-											chosen := s.Choose(funds, 0)
-											for _, f := range chosen {
-												fmt.Println(f.Print())
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-					c <- true
-				}(ret, monthsToRead, minMonths)
-			}
-		}
+	space := &search.Space{
+		MinNumFunds:  *numFunds,
+		MaxNumFunds:  *numFunds,
+		MinMaxMonths: *maxMonths,
+		MaxMaxMonths: *maxMonths,
+		MinMinMonths: *minMonths,
+		MaxMinMonths: *minMonths,
+		FeatureCount: funds[0].FeatureCount(),
+	}
+	s := search.NewSearch(space, backtest, 0, value)
+	// The structure is fixed, and each fund is chosen by the same slot.
+	values := [][]float64{{0}, {0}, {0}}
+	for i := 0; i < *numFunds; i++ {
+		values = append(values,
+			optimize.Range(0.15625, 0.15625, step),
+			optimize.Range(0.28125, 0.28125, step),
+			optimize.Range(0.8125, 0.8125, step),
+			optimize.Range(-0.46875, -0.46875, step),
+			optimize.Range(0, 0, step),
+			optimize.Range(-0.28125, -0.28125, step),
+			[]float64{-1},
+			[]float64{-1},
+		)
+	}
+	grid := &optimize.Grid{
+		Values: values,
+		Step:   step,
 	}
-	for i := 0; i < n; i++ {
-		<-c
+	_, err = grid.Optimize(&optimize.Problem{
+		Objective:       s.Objective,
+		Bounds:          optimize.Weights,
+		Checkpoint:      *checkpoint,
+		CheckpointEvery: *every,
+		Resume:          resumed,
+		Progress: func(p *optimize.Progress) {
+			e, err := s.Evaluate(p.Point)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			st, r := e.Candidate.Strategy(), e.Result
+			fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", st.Name(), r.Median, r.Mean, r.Worst, r.BeatCDI, r.MedianRelative(simulate.CDI))
+			if print {
+				chosen, err := st.Choose(xpfunds.Investable(funds, profile, 0), 0)
+				if err != nil {
+					fmt.Println(err)
+				}
+				for _, f := range chosen {
+					fmt.Println(f.Print())
+				}
+			}
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
 	}
 }